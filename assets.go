@@ -0,0 +1,8 @@
+// Package myblog embeds the default theme, i.e. the page templates and the
+// static assets, so the binaries run from any working directory.
+package myblog
+
+import "embed"
+
+//go:embed internal/web/templates static
+var Assets embed.FS
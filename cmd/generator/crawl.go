@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+)
+
+// crawlSkipPrefixes are same-origin paths the crawler does not render:
+// static files and uploads are copied separately, the admin is not public.
+var crawlSkipPrefixes = []string{"/static/", "/uploads/", "/admin"}
+
+// sitemapLocPattern finds the page URLs listed in a sitemap.
+var sitemapLocPattern = regexp.MustCompile(`<loc>\s*([^<\s]+)\s*</loc>`)
+
+// crawlFailure is a route that answered with a 4xx or 5xx status.
+type crawlFailure struct {
+	Route  string
+	Status int
+	// From is the page that linked to the route, empty for seeds.
+	From string
+}
+
+// crawler renders the site by following links from a set of seed routes.
+// Every route is rendered once, with at most cap(sem) renders in flight.
+type crawler struct {
+	mux       http.Handler
+	man       *manifest
+	in        *inputs
+	outputDir string
+	baseURL   string
+
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu       sync.Mutex
+	seen     map[string]bool
+	failures []crawlFailure
+
+	rendered, skipped, written, identical atomic.Int64
+}
+
+func newCrawler(mux http.Handler, man *manifest, in *inputs, outputDir, baseURL string, workers int) *crawler {
+	return &crawler{
+		mux:       mux,
+		man:       man,
+		in:        in,
+		outputDir: outputDir,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		sem:       make(chan struct{}, workers),
+		seen:      map[string]bool{},
+	}
+}
+
+// run crawls from seeds and returns once every reachable route is done.
+func (c *crawler) run(seeds []string) {
+	for _, seed := range seeds {
+		c.visit(seed, "")
+	}
+	c.wg.Wait()
+}
+
+// routes returns the number of distinct routes visited.
+func (c *crawler) routes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.seen)
+}
+
+// visit queues a link found on page from, unless it leaves the site or was
+// already queued.
+func (c *crawler) visit(link, from string) {
+	route, ok := c.route(link)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	if c.seen[route] {
+		c.mu.Unlock()
+		return
+	}
+	c.seen[route] = true
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.sem <- struct{}{}
+		defer func() { <-c.sem }()
+		c.crawl(route, from)
+	}()
+}
+
+// route turns a link into the site path it points to. Links to other
+// sites, in-page anchors and skipped prefixes are rejected; query strings
+// and fragments are dropped because a static site cannot serve them.
+func (c *crawler) route(link string) (string, bool) {
+	link = strings.TrimSpace(link)
+	if c.baseURL != "" && (link == c.baseURL || strings.HasPrefix(link, c.baseURL+"/")) {
+		link = strings.TrimPrefix(link, c.baseURL)
+		if link == "" {
+			link = "/"
+		}
+	}
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		return "", false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	route := u.Path
+	if route != "/" {
+		route = strings.TrimRight(route, "/")
+	}
+	for _, prefix := range crawlSkipPrefixes {
+		if strings.HasPrefix(route, prefix) {
+			return "", false
+		}
+	}
+	return route, true
+}
+
+func (c *crawler) crawl(route, from string) {
+	inputs := c.in.For(route)
+
+	// 未变化的页面不再渲染，但仍要从已有文件里找出链接继续爬取
+	if rel, ok := c.man.fileOf(route); ok && c.man.fresh(c.outputDir, rel, inputs) {
+		c.skipped.Add(1)
+		data, err := os.ReadFile(filepath.Join(c.outputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			log.Printf("Warning: failed to read %s: %v", rel, err)
+			return
+		}
+		c.follow(route, links(data, contentTypeOf(rel)))
+		return
+	}
+
+	target := (&url.URL{Path: route}).EscapedPath()
+	req := httptest.NewRequest("GET", target, nil)
+	w := httptest.NewRecorder()
+	c.mux.ServeHTTP(w, req)
+	c.rendered.Add(1)
+
+	switch {
+	case w.Code >= 300 && w.Code < 400:
+		// 重定向不生成文件，只跟随目标地址
+		if location := w.Header().Get("Location"); location != "" {
+			c.visit(location, route)
+		}
+		return
+	case w.Code != http.StatusOK:
+		c.mu.Lock()
+		c.failures = append(c.failures, crawlFailure{Route: route, Status: w.Code, From: from})
+		c.mu.Unlock()
+		return
+	}
+
+	body := w.Body.Bytes()
+	contentType := w.Header().Get("Content-Type")
+	rel := outputPath(route, contentType)
+	entry := manifestEntry{Route: route, Inputs: inputs, Hash: contentHash(body), Size: int64(len(body))}
+	// 内容没变的文件不重写，保留原来的修改时间
+	if c.man.unchanged(c.outputDir, rel, body) {
+		c.identical.Add(1)
+	} else {
+		if err := writeOutput(filepath.Join(c.outputDir, filepath.FromSlash(rel)), body); err != nil {
+			log.Fatalf("Failed to write %s: %v", rel, err)
+		}
+		fmt.Printf("Generated %s\n", route)
+		c.written.Add(1)
+	}
+	c.man.record(rel, entry)
+	c.follow(route, links(body, contentType))
+}
+
+func (c *crawler) follow(from string, links []string) {
+	for _, link := range links {
+		c.visit(link, from)
+	}
+}
+
+// sortedFailures returns the failures ordered by route.
+func (c *crawler) sortedFailures() []crawlFailure {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	failures := append([]crawlFailure(nil), c.failures...)
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Route < failures[j].Route })
+	return failures
+}
+
+// outputPath maps a route to its file under the output directory. HTML pages
+// use clean URLs: / -> index.html, /posts/slug -> posts/slug/index.html.
+// Everything else, such as feeds, sitemaps and images, keeps its own name.
+func outputPath(route, contentType string) string {
+	name := strings.TrimPrefix(path.Clean(route), "/")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType != "text/html" && name != "":
+		return name
+	case path.Ext(name) == ".html":
+		return name
+	case name == "":
+		return "index.html"
+	default:
+		return name + "/index.html"
+	}
+}
+
+// contentTypeOf guesses the content type of a file written by an earlier run.
+func contentTypeOf(rel string) string {
+	if strings.HasSuffix(rel, ".html") {
+		return "text/html"
+	}
+	return mime.TypeByExtension(path.Ext(rel))
+}
+
+// links returns the link targets in a page: a and link hrefs and share
+// images in HTML, and the page URLs of a sitemap.
+func links(body []byte, contentType string) []string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html":
+		return htmlLinks(body)
+	case "application/xml", "text/xml":
+		var found []string
+		for _, m := range sitemapLocPattern.FindAllSubmatch(body, -1) {
+			found = append(found, html.UnescapeString(string(m[1])))
+		}
+		return found
+	}
+	return nil
+}
+
+func htmlLinks(body []byte) []string {
+	var found []string
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return found
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+			switch string(tag) {
+			case "a", "link":
+				if href := attrs["href"]; href != "" {
+					found = append(found, href)
+				}
+			case "meta":
+				// 分享图只出现在 meta 标签里
+				switch attrs["property"] + attrs["name"] {
+				case "og:image", "twitter:image":
+					found = append(found, attrs["content"])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		route       string
+		contentType string
+		want        string
+	}{
+		{"/", "text/html; charset=utf-8", "index.html"},
+		{"/posts", "text/html; charset=utf-8", "posts/index.html"},
+		{"/posts/hello", "text/html; charset=utf-8", "posts/hello/index.html"},
+		{"/posts/hello/", "text/html; charset=utf-8", "posts/hello/index.html"},
+		{"/tags/并发", "text/html; charset=utf-8", "tags/并发/index.html"},
+		{"/404.html", "text/html; charset=utf-8", "404.html"},
+		{"/feed.xml", "application/rss+xml; charset=utf-8", "feed.xml"},
+		{"/sitemaps/posts.xml", "application/xml", "sitemaps/posts.xml"},
+		{"/robots.txt", "text/plain; charset=utf-8", "robots.txt"},
+		{"/og/hello.png", "image/png", "og/hello.png"},
+		{"/posts/../robots.txt", "text/plain", "robots.txt"},
+		{"/", "", "index.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route+" "+tt.contentType, func(t *testing.T) {
+			if got := outputPath(tt.route, tt.contentType); got != tt.want {
+				t.Errorf("outputPath(%q, %q) = %q, want %q", tt.route, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlerRoute(t *testing.T) {
+	c := newCrawler(nil, nil, nil, "dist", "https://example.com/", 1)
+	tests := []struct {
+		link string
+		want string
+		ok   bool
+	}{
+		{"/", "/", true},
+		{"/posts/hello/", "/posts/hello", true},
+		{"/posts/hello?page=2#top", "/posts/hello", true},
+		{"/tags/%E5%B9%B6%E5%8F%91", "/tags/并发", true},
+		{"https://example.com", "/", true},
+		{"https://example.com/posts", "/posts", true},
+		{" /archive ", "/archive", true},
+		{"https://example.com.evil/posts", "", false},
+		{"https://other.com/posts", "", false},
+		{"//example.com/posts", "", false},
+		{"#top", "", false},
+		{"posts/hello", "", false},
+		{"mailto:me@example.com", "", false},
+		{"/static/css/app.css", "", false},
+		{"/uploads/img/a.png", "", false},
+		{"/admin/posts", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			got, ok := c.route(tt.link)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("route(%q) = %q, %v, want %q, %v", tt.link, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        []string
+	}{
+		{
+			name:        "html",
+			body:        `<link rel="alternate" href="/feed.xml"><meta property="og:image" content="/og/a.png"><meta name="twitter:image" content="/og/b.png"><meta name="description" content="x"><a href="/posts/a">a</a><a>none</a><img src="/uploads/x.png">`,
+			contentType: "text/html; charset=utf-8",
+			want:        []string{"/feed.xml", "/og/a.png", "/og/b.png", "/posts/a"},
+		},
+		{
+			name:        "sitemap",
+			body:        "<urlset><url><loc>https://example.com/posts/a</loc></url><url><loc>\n https://example.com/tags/a&amp;b \n</loc></url></urlset>",
+			contentType: "application/xml",
+			want:        []string{"https://example.com/posts/a", "https://example.com/tags/a&b"},
+		},
+		{
+			name:        "other types are not followed",
+			body:        `<a href="/posts/a">a</a>`,
+			contentType: "text/plain",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := links([]byte(tt.body), tt.contentType); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("links = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
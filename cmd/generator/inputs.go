@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"myblog/internal/blog"
+	"myblog/internal/config"
+	"myblog/internal/web"
+)
+
+// inputs works out what each route's output depends on. A route whose
+// inputs hash matches the manifest is not rendered again.
+type inputs struct {
+	store  blog.Store
+	series blog.SeriesStore
+	pages  blog.PageStore
+	srv    *web.Server
+	// global covers what every page shows: the generator binary, the theme,
+	// the site settings, the navigation and the feed links, which list the
+	// languages of all published posts
+	global string
+	// allPosts changes whenever any published post does; list pages, feeds
+	// and sitemaps depend on it
+	allPosts string
+}
+
+func newInputs(cfg *config.Config, store blog.Store, series blog.SeriesStore, pages blog.PageStore, siteStore *blog.SiteStore, srv *web.Server) *inputs {
+	theme, err := srv.ThemeSignature()
+	if err != nil {
+		log.Printf("Warning: failed to hash theme files: %v", err)
+		theme = time.Now().String()
+	}
+	var nav []blog.Page
+	for _, page := range pages.ListPages() {
+		if page.ShowInNav && !page.IsDraft {
+			nav = append(nav, page)
+		}
+	}
+	return &inputs{
+		store:    store,
+		series:   series,
+		pages:    pages,
+		srv:      srv,
+		global:   hashOf(executableHash(), theme, cfg.SiteBaseURL, jsonString(siteStore.Get()), jsonString(nav), jsonString(srv.FeedLanguages())),
+		allPosts: stamps(store.ListPublished()),
+	}
+}
+
+// executableHash lets a rebuilt generator, whose rendering code may differ,
+// re-render every page.
+func executableHash() string {
+	path, err := os.Executable()
+	if err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			return contentHash(data)
+		}
+	}
+	return time.Now().String()
+}
+
+// For returns the inputs hash of a route.
+func (in *inputs) For(route string) string {
+	switch {
+	case strings.HasPrefix(route, "/posts/"):
+		post, ok := in.store.GetBySlug(strings.TrimPrefix(route, "/posts/"))
+		if !ok {
+			break
+		}
+		return in.postInputs(post)
+
+	case strings.HasPrefix(route, "/og/"):
+		slug := strings.TrimSuffix(strings.TrimPrefix(route, "/og/"), ".png")
+		if post, ok := in.store.GetBySlug(slug); ok {
+			return hashOf(in.global, route, jsonString(post))
+		}
+
+	case strings.HasPrefix(route, "/tags/"):
+		return hashOf(in.global, route, stamps(in.store.ListByTag(strings.TrimPrefix(route, "/tags/"))))
+
+	case strings.HasPrefix(route, "/categories/"):
+		return hashOf(in.global, route, stamps(in.store.ListByCategory(strings.TrimPrefix(route, "/categories/"))))
+
+	case strings.HasPrefix(route, "/series/"):
+		slug := strings.TrimPrefix(route, "/series/")
+		series, _ := in.series.GetSeries(slug)
+		return hashOf(in.global, route, jsonString(series), stamps(in.store.ListBySeries(slug)))
+
+	default:
+		// 独立页面的短代码可能引用文章，因此也依赖全部文章
+		if page, ok := in.pages.GetPage(strings.Trim(route, "/")); ok {
+			return hashOf(in.global, route, jsonString(page), in.allPosts)
+		}
+	}
+	return hashOf(in.global, route, in.allPosts)
+}
+
+// postInputs covers the post itself and every other post its page shows:
+// neighbours, related posts, backlinks, its series and its translations.
+func (in *inputs) postInputs(post blog.Post) string {
+	var shown []blog.Post
+	if prev, ok := in.store.PrevPost(post.Slug); ok {
+		shown = append(shown, prev)
+	}
+	if next, ok := in.store.NextPost(post.Slug); ok {
+		shown = append(shown, next)
+	}
+	shown = append(shown, in.store.GetRelated(post.Slug, 3)...)
+	shown = append(shown, in.srv.Backlinks(post.Slug)...)
+	shown = append(shown, in.store.ListTranslations(post.TranslationGroup())...)
+	var series string
+	if post.Series != "" {
+		s, _ := in.series.GetSeries(post.Series)
+		series = jsonString(s)
+		shown = append(shown, in.store.ListBySeries(post.Series)...)
+	}
+	return hashOf(in.global, "/posts/"+post.Slug, jsonString(post), series, stamps(shown))
+}
+
+// stamps identifies a list of posts by slug and last update; editing any of
+// them changes the result.
+func stamps(posts []blog.Post) string {
+	var b strings.Builder
+	for _, post := range posts {
+		b.WriteString(post.Slug)
+		b.WriteByte('@')
+		b.WriteString(post.UpdatedAt.UTC().Format(time.RFC3339Nano))
+		b.WriteByte('\n')
+	}
+	return hashOf(b.String())
+}
+
+func jsonString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return time.Now().String()
+	}
+	return string(data)
+}
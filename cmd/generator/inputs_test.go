@@ -0,0 +1,157 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"myblog/internal/blog"
+	"myblog/internal/config"
+	"myblog/internal/web"
+)
+
+// inputsFixture is a small site: two Go posts, a Rust post in a series and
+// an About page.
+type inputsFixture struct {
+	cfg       *config.Config
+	sql       *blog.SQLiteStore
+	store     blog.Store
+	siteStore *blog.SiteStore
+}
+
+func newInputsFixture(t *testing.T) *inputsFixture {
+	t.Helper()
+	dir := t.TempDir()
+	sqlStore, err := blog.NewSQLiteStore(filepath.Join(dir, "blog.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { sqlStore.Close() })
+	siteStore, err := blog.NewSiteStore(filepath.Join(dir, "site.json"))
+	if err != nil {
+		t.Fatalf("open site store: %v", err)
+	}
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := sqlStore.CreateSeries(blog.Series{Slug: "rust-intro", Name: "Rust 入门"}); err != nil {
+		t.Fatalf("create series: %v", err)
+	}
+	for _, post := range []blog.Post{
+		{Slug: "go-a", Title: "Go A", Content: "a", Category: "dev", Tags: []string{"go"}, CreatedAt: day},
+		{Slug: "go-b", Title: "Go B", Content: "b", Category: "dev", Tags: []string{"go"}, CreatedAt: day.AddDate(0, 0, 1)},
+		{Slug: "rust-a", Title: "Rust A", Content: "c", Category: "notes", Tags: []string{"rust"}, Series: "rust-intro", SeriesOrder: 1, CreatedAt: day.AddDate(0, 0, 2)},
+	} {
+		if err := sqlStore.Create(post); err != nil {
+			t.Fatalf("create %s: %v", post.Slug, err)
+		}
+	}
+	if err := sqlStore.CreatePage(blog.Page{Path: "about", Title: "关于", Content: "about"}); err != nil {
+		t.Fatalf("create page: %v", err)
+	}
+	return &inputsFixture{
+		cfg:       &config.Config{SiteBaseURL: "https://example.com"},
+		sql:       sqlStore,
+		store:     blog.NewRelatedStore(sqlStore),
+		siteStore: siteStore,
+	}
+}
+
+var inputsRoutes = []string{
+	"/", "/posts", "/posts/go-a", "/posts/rust-a", "/og/go-a.png", "/og/rust-a.png",
+	"/tags/go", "/tags/rust", "/categories/dev", "/categories/notes", "/series/rust-intro", "/about",
+}
+
+// hashes returns the inputs hash of every route, as a new generator run would.
+func (f *inputsFixture) hashes() map[string]string {
+	srv := web.NewServer(f.cfg, f.store, f.sql, f.sql, f.siteStore)
+	in := newInputs(f.cfg, f.store, f.sql, f.sql, f.siteStore, srv)
+	hashes := map[string]string{}
+	for _, route := range inputsRoutes {
+		hashes[route] = in.For(route)
+	}
+	return hashes
+}
+
+func TestInputsFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(t *testing.T, f *inputsFixture)
+		changed   []string
+		unchanged []string
+	}{
+		{
+			name:      "no change",
+			change:    func(t *testing.T, f *inputsFixture) {},
+			unchanged: inputsRoutes,
+		},
+		{
+			name: "edit a post",
+			change: func(t *testing.T, f *inputsFixture) {
+				post, _ := f.sql.GetBySlug("go-a")
+				post.Content = "a, edited"
+				if err := f.store.Update("go-a", post); err != nil {
+					t.Fatal(err)
+				}
+			},
+			changed:   []string{"/", "/posts", "/posts/go-a", "/og/go-a.png", "/tags/go", "/categories/dev", "/about"},
+			unchanged: []string{"/og/rust-a.png", "/tags/rust", "/categories/notes", "/series/rust-intro"},
+		},
+		{
+			name: "edit a page",
+			change: func(t *testing.T, f *inputsFixture) {
+				page, _ := f.sql.GetPage("about")
+				page.Content = "about, edited"
+				if err := f.sql.UpdatePage("about", page); err != nil {
+					t.Fatal(err)
+				}
+			},
+			changed:   []string{"/about"},
+			unchanged: []string{"/", "/posts", "/posts/go-a", "/tags/go", "/series/rust-intro"},
+		},
+		{
+			name: "edit a series",
+			change: func(t *testing.T, f *inputsFixture) {
+				if err := f.sql.UpdateSeries("rust-intro", blog.Series{Slug: "rust-intro", Name: "Rust 入门", Description: "新描述"}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			changed:   []string{"/series/rust-intro", "/posts/rust-a"},
+			unchanged: []string{"/posts", "/posts/go-a", "/tags/rust", "/about"},
+		},
+		{
+			// 多语言时每页都有按语言的订阅链接
+			name: "add a post language",
+			change: func(t *testing.T, f *inputsFixture) {
+				post, _ := f.sql.GetBySlug("rust-a")
+				post.Lang = "en"
+				if err := f.store.Update("rust-a", post); err != nil {
+					t.Fatal(err)
+				}
+			},
+			changed: []string{"/tags/go", "/categories/dev", "/og/go-a.png", "/about"},
+		},
+		{
+			name:    "change the base URL",
+			change:  func(t *testing.T, f *inputsFixture) { f.cfg.SiteBaseURL = "https://blog.example.com" },
+			changed: inputsRoutes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newInputsFixture(t)
+			before := f.hashes()
+			tt.change(t, f)
+			after := f.hashes()
+			for _, route := range tt.changed {
+				if before[route] == after[route] {
+					t.Errorf("%s: inputs unchanged, want a new hash", route)
+				}
+			}
+			for _, route := range tt.unchanged {
+				if before[route] != after[route] {
+					t.Errorf("%s: inputs changed, want the same hash", route)
+				}
+			}
+		})
+	}
+}
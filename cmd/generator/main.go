@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
-	"net/http"
-	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"myblog/internal/blog"
 	"myblog/internal/config"
@@ -18,7 +22,17 @@ import (
 
 func main() {
 	baseURL := flag.String("base-url", "", "Override the site base URL")
+	checkLinks := flag.Bool("check-links", false, "Check internal links in every post and fail on broken ones")
+	checkExternal := flag.Bool("check-external", false, "With -check-links, also request external links")
+	linkConcurrency := flag.Int("link-concurrency", 8, "Maximum concurrent external link checks")
+	clean := flag.Bool("clean", false, "Delete the output directory and render everything again")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of routes rendered in parallel")
+	allowErrors := flag.Bool("allow-errors", false, "Finish the build even when a route answers with a 4xx or 5xx status")
 	flag.Parse()
+	if *workers < 1 {
+		*workers = 1
+	}
+	buildStart := time.Now()
 
 	// 1. Load config and store
 	cfg := config.Load()
@@ -27,11 +41,16 @@ func main() {
 	}
 
 	dbPath := filepath.Join(cfg.DataDir, "blog.db")
-	store, err := blog.NewSQLiteStore(dbPath)
+	sqlStore, err := blog.NewSQLiteStore(dbPath)
 	if err != nil {
 		log.Fatalf("Failed to open store: %v", err)
 	}
 
+	// Build the related-posts index once up front; every post page reads from it
+	start := time.Now()
+	store := blog.NewRelatedStore(sqlStore)
+	fmt.Printf("Built related-posts index in %s\n", time.Since(start).Round(time.Millisecond))
+
 	sitePath := filepath.Join(cfg.DataDir, "site.json")
 	siteStore, err := blog.NewSiteStore(sitePath)
 	if err != nil {
@@ -39,125 +58,161 @@ func main() {
 	}
 
 	// 2. Initialize Server
-	srv := web.NewServer(cfg, store, siteStore)
+	srv := web.NewServer(cfg, store, sqlStore, sqlStore, siteStore)
+	for _, w := range srv.ShortcodeWarnings() {
+		log.Printf("Warning: post %s: %s", w.Post, w.Message)
+	}
+
+	// 3. Optional link check
+	// 在写入输出目录之前检查，有断链时不覆盖上次的构建
+	if *checkLinks {
+		report := srv.CheckLinks(context.Background(), web.LinkCheckOptions{
+			External:    *checkExternal,
+			Concurrency: *linkConcurrency,
+		})
+		fmt.Printf("Checked %d links in %d posts in %s\n", report.Links, report.Posts, report.Duration.Round(time.Millisecond))
+		for _, post := range report.Broken {
+			fmt.Printf("  %s (%s)\n", post.Title, post.Slug)
+			for _, link := range post.Links {
+				fmt.Printf("    %s: %s\n", link.URL, link.Reason)
+			}
+		}
+		if n := report.BrokenCount(); n > 0 {
+			log.Fatalf("Found %d broken link(s)", n)
+		}
+	}
 
-	// 3. Prepare output directory
+	// 4. Prepare output directory
+	// 默认保留上次的输出，只重新生成输入变化过的文件
 	outputDir := "dist"
-	if err := os.RemoveAll(outputDir); err != nil {
-		log.Printf("Warning: failed to clean dist dir: %v", err)
+	if *clean {
+		if err := os.RemoveAll(outputDir); err != nil {
+			log.Printf("Warning: failed to clean dist dir: %v", err)
+		}
 	}
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		log.Fatalf("Failed to create dist dir: %v", err)
 	}
-
-	// 4. Define routes to crawl
-	routes := []string{"/"} // Index
-
-	// Add all posts
-	posts := store.ListPublished()
-	for _, p := range posts {
-		routes = append(routes, "/posts/"+p.Slug)
-	}
-	// Add posts list page
-	routes = append(routes, "/posts")
-	// Add archive page
-	routes = append(routes, "/archive")
-	// routes = append(routes")
-	// Add paginated index pages (static)
-	totalPosts := len(posts)
-	totalPages := (totalPosts + web.IndexPageSize - 1) / web.IndexPageSize
-	if totalPages < 1 {
-		totalPages = 1
-	}
-	for i := 2; i <= totalPages; i++ {
-		routes = append(routes, fmt.Sprintf("/page/%d", i))
+	man := loadManifest(outputDir)
+	in := newInputs(cfg, store, sqlStore, sqlStore, siteStore, srv)
+
+	// 5. Seed the crawl
+	// 从首页开始沿站内链接爬取；没有页面链接到的路由在这里列出
+	seeds := []string{"/", "/search", "/feed.xml"}
+	seeds = append(seeds, web.SitemapPaths()...)
+	for _, p := range store.ListPublished() {
+		seeds = append(seeds, "/og/"+p.Slug+".png")
 	}
 
-	// 5. Generate pages
-	mux := srv.PublicRoutes()
-
-	for _, route := range routes {
-		fmt.Printf("Generating %s...\n", route)
-		req := httptest.NewRequest("GET", route, nil)
-		w := httptest.NewRecorder()
+	// 6. Generate pages
+	renderStart := time.Now()
+	crawl := newCrawler(srv.PublicRoutes(), man, in, outputDir, cfg.SiteBaseURL, *workers)
+	crawl.run(seeds)
+	renderTime := time.Since(renderStart)
 
-		mux.ServeHTTP(w, req)
-
-		resp := w.Result()
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("Error generating %s: status %d", route, resp.StatusCode)
-			continue
-		}
-
-		// Determine output file path
-		// / -> index.html
-		// /posts/slug -> posts/slug/index.html (for clean URLs) OR posts/slug.html
-		// Let's use clean URLs: posts/slug/index.html
-		relPath := route
-		if relPath == "/" {
-			relPath = "index.html"
+	failures := crawl.sortedFailures()
+	for _, f := range failures {
+		if f.From != "" {
+			log.Printf("Error generating %s: status %d (linked from %s)", f.Route, f.Status, f.From)
 		} else {
-			relPath = relPath + "/index.html"
-		}
-		// Remove leading slash for filepath.Join
-		relPath = filepath.Clean(relPath) // trims leading / on windows sometimes? handled better manually
-		if relPath[0] == '\\' || relPath[0] == '/' {
-			relPath = relPath[1:]
+			log.Printf("Error generating %s: status %d", f.Route, f.Status)
 		}
+	}
 
-		outPath := filepath.Join(outputDir, relPath)
-		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-			log.Fatalf("Failed to create dir for %s: %v", outPath, err)
-		}
+	// 7. Copy static assets
+	// 静态文件按普通文件名和带指纹的文件名各写一份，记入清单，旧指纹的文件随后被清理
+	staticWritten, staticUnchanged, err := srv.ExportStatic(filepath.Join(outputDir, "static"), func(name string, data []byte) {
+		hash := contentHash(data)
+		man.record("static/"+name, manifestEntry{Inputs: hash, Hash: hash, Size: int64(len(data))})
+	})
+	if err != nil {
+		log.Fatalf("Failed to copy static assets: %v", err)
+	}
+	uploadsCopied, uploadsUnchanged, err := syncUploads(man, "uploads", outputDir)
+	if err != nil {
+		log.Fatalf("Failed to copy uploads: %v", err)
+	}
 
-		f, err := os.Create(outPath)
+	// 构建失败时保留上次的文件，避免删掉只是暂时出错的页面
+	removed := 0
+	if len(failures) == 0 || *allowErrors {
+		removed, err = man.prune(outputDir)
 		if err != nil {
-			log.Fatalf("Failed to create file %s: %v", outPath, err)
+			log.Fatalf("Failed to remove stale files: %v", err)
 		}
-
-		body, _ := io.ReadAll(resp.Body)
-		f.Write(body)
-		f.Close()
-		resp.Body.Close()
+	}
+	if err := man.save(outputDir); err != nil {
+		log.Fatalf("Failed to save manifest: %v", err)
 	}
 
-	// 6. Copy static assets
-	fmt.Println("Copying static assets...")
-	copyDir("static", filepath.Join(outputDir, "static"))
-	copyDir("uploads", filepath.Join(outputDir, "uploads"))
-
-	fmt.Println("Done! Static site generated in 'dist' directory.")
+	fmt.Printf("Routes:  %d crawled, %d rendered, %d skipped as fresh, %d failed (%d workers, %s)\n",
+		crawl.routes(), crawl.rendered.Load(), crawl.skipped.Load(), len(failures), *workers, renderTime.Round(time.Millisecond))
+	fmt.Printf("Files:   %d written, %d identical, %d stale removed\n", crawl.written.Load(), crawl.identical.Load(), removed)
+	fmt.Printf("Static:  %d written, %d unchanged (theme: %s)\n", staticWritten, staticUnchanged, srv.ActiveTheme().Manifest.Name)
+	fmt.Printf("Uploads: %d copied, %d unchanged\n", uploadsCopied, uploadsUnchanged)
+	if len(failures) > 0 && !*allowErrors {
+		log.Fatalf("%d route(s) failed; rerun with -allow-errors to build anyway", len(failures))
+	}
+	fmt.Printf("Done in %s! Static site generated in 'dist' directory.\n", time.Since(buildStart).Round(time.Millisecond))
 }
 
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+func writeOutput(target string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0644)
+}
 
-		relPath, err := filepath.Rel(src, path)
+// syncUploads copies src into the uploads directory of the output, skipping
+// files whose size and modification time match the manifest.
+func syncUploads(man *manifest, src, outputDir string) (copied, unchanged int, err error) {
+	err = filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
 		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && name == src {
+				return nil
+			}
 			return err
 		}
-		targetPath := filepath.Join(dst, relPath)
-
 		if info.IsDir() {
-			return os.MkdirAll(targetPath, info.Mode())
+			return nil
 		}
-
-		sourceFile, err := os.Open(path)
+		relPath, err := filepath.Rel(src, name)
 		if err != nil {
 			return err
 		}
-		defer sourceFile.Close()
-
-		destFile, err := os.Create(targetPath)
-		if err != nil {
+		rel := "uploads/" + filepath.ToSlash(relPath)
+		inputs := hashOf(strconv.FormatInt(info.Size(), 10), info.ModTime().UTC().Format(time.RFC3339Nano))
+		if man.fresh(outputDir, rel, inputs) {
+			unchanged++
+			return nil
+		}
+		if err := copyFile(name, filepath.Join(outputDir, filepath.FromSlash(rel))); err != nil {
 			return err
 		}
-		defer destFile.Close()
+		man.record(rel, manifestEntry{Inputs: inputs, Size: info.Size()})
+		copied++
+		return nil
+	})
+	return copied, unchanged, err
+}
 
-		_, err = io.Copy(destFile, sourceFile)
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return err
-	})
+	}
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destFile, sourceFile); err != nil {
+		destFile.Close()
+		return err
+	}
+	return destFile.Close()
 }
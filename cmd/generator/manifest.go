@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// manifestFile sits in the output directory and records how every generated
+// file was produced, so the next run can skip the ones that are still fresh.
+const manifestFile = ".manifest.json"
+
+// manifestVersion changes whenever the meaning of the recorded hashes does;
+// a manifest with another version is ignored and everything is rebuilt.
+const manifestVersion = 1
+
+type manifestEntry struct {
+	Route string `json:"route,omitempty"`
+	// Inputs hashes what the file was built from: a route's dependencies, an
+	// upload's size and modification time, or a static file's contents.
+	Inputs string `json:"inputs"`
+	// Hash is the SHA-256 of the file contents.
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+type manifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]manifestEntry `json:"files"`
+
+	mu   sync.Mutex
+	seen map[string]bool
+	// routes maps each route of the previous run to the file it produced
+	routes map[string]string
+}
+
+// loadManifest reads the manifest of dir, or returns an empty one when there
+// is none or it cannot be used.
+func loadManifest(dir string) *manifest {
+	m := &manifest{Version: manifestVersion, Files: map[string]manifestEntry{}, seen: map[string]bool{}, routes: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return m
+	}
+	var stored manifest
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != manifestVersion || stored.Files == nil {
+		return m
+	}
+	m.Files = stored.Files
+	for rel, entry := range m.Files {
+		if entry.Route != "" {
+			m.routes[entry.Route] = rel
+		}
+	}
+	return m
+}
+
+// fileOf returns the file route produced in the previous run.
+func (m *manifest) fileOf(route string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rel, ok := m.routes[route]
+	return rel, ok
+}
+
+// fresh reports whether rel was built from the same inputs and is still on
+// disk unchanged in size. A fresh file is kept by prune.
+func (m *manifest) fresh(dir, rel, inputs string) bool {
+	m.mu.Lock()
+	entry, ok := m.Files[rel]
+	m.mu.Unlock()
+	if !ok || entry.Inputs != inputs {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil || info.Size() != entry.Size {
+		return false
+	}
+	m.keep(rel)
+	return true
+}
+
+// unchanged reports whether rel already holds exactly data.
+func (m *manifest) unchanged(dir, rel string, data []byte) bool {
+	m.mu.Lock()
+	entry, ok := m.Files[rel]
+	m.mu.Unlock()
+	if !ok || entry.Hash != contentHash(data) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+	return err == nil && info.Size() == entry.Size
+}
+
+func (m *manifest) record(rel string, entry manifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files[rel] = entry
+	m.seen[rel] = true
+}
+
+func (m *manifest) keep(rel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seen[rel] = true
+}
+
+// prune deletes the files of earlier runs that this run did not produce,
+// such as pages of deleted posts, and returns how many were removed.
+func (m *manifest) prune(dir string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stale []string
+	for rel := range m.Files {
+		if !m.seen[rel] {
+			stale = append(stale, rel)
+		}
+	}
+	sort.Strings(stale)
+	for _, rel := range stale {
+		err := os.Remove(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+		// 顺带删除因此变空的目录，非空目录删除失败即停止
+		for parent := path.Dir(rel); parent != "."; parent = path.Dir(parent) {
+			if os.Remove(filepath.Join(dir, filepath.FromSlash(parent))) != nil {
+				break
+			}
+		}
+		delete(m.Files, rel)
+	}
+	return len(stale), nil
+}
+
+func (m *manifest) save(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), data, 0644)
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashOf hashes a list of strings; a part boundary can never be mistaken for
+// part of a value.
+func hashOf(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
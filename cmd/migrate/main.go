@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"myblog/internal/blog"
+	"myblog/internal/config"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-db path] <command>\n\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  status   Show applied and pending migrations")
+	fmt.Fprintln(os.Stderr, "  up       Apply all pending migrations")
+	fmt.Fprintln(os.Stderr)
+	flag.PrintDefaults()
+}
+
+func main() {
+	dbPath := flag.String("db", "", "Path to the SQLite database (default: $DATA_DIR/blog.db)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	if *dbPath == "" {
+		*dbPath = filepath.Join(cfg.DataDir, "blog.db")
+	}
+
+	// status 只读：数据库文件不存在时直接列出全部待执行的迁移，不去创建它
+	if flag.Arg(0) == "status" {
+		if _, err := os.Stat(*dbPath); errors.Is(err, fs.ErrNotExist) {
+			migrations, err := blog.Migrations()
+			if err != nil {
+				log.Fatalf("Failed to read migrations: %v", err)
+			}
+			for _, m := range migrations {
+				fmt.Printf("%04d  %-32s %s\n", m.Version, m.Name, "pending")
+			}
+			fmt.Printf("\nNo migrations applied: %s does not exist. Pending: %d\n", *dbPath, len(migrations))
+			return
+		}
+	}
+
+	// 这里不能用 NewSQLiteStore，否则打开时就会自动迁移
+	store, err := blog.OpenSQLiteStore(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	switch flag.Arg(0) {
+	case "status":
+		statuses, err := store.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		pending := 0
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			} else {
+				pending++
+			}
+			fmt.Printf("%04d  %-32s %s\n", st.Version, st.Name, state)
+		}
+		version, err := store.SchemaVersion()
+		if err != nil {
+			log.Fatalf("Failed to read schema version: %v", err)
+		}
+		if version == 0 {
+			fmt.Printf("\nNo migrations applied. Pending: %d\n", pending)
+		} else {
+			fmt.Printf("\nSchema version: %d, pending: %d\n", version, pending)
+		}
+	case "up":
+		n, err := store.Migrate()
+		if err != nil {
+			log.Fatalf("Migration failed after %d applied: %v", n, err)
+		}
+		version, _ := store.SchemaVersion()
+		fmt.Printf("Applied %d migration(s), schema version is now %d.\n", n, version)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
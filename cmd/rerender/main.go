@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"myblog/internal/blog"
+	"myblog/internal/config"
+	"myblog/internal/web"
+)
+
+// rerender 重新生成所有文章与页面的预渲染 HTML，在升级渲染器或 Markdown 扩展后运行
+func main() {
+	flag.Parse()
+
+	cfg := config.Load()
+
+	store, err := blog.NewSQLiteStore(filepath.Join(cfg.DataDir, "blog.db"))
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	siteStore, err := blog.NewSiteStore(filepath.Join(cfg.DataDir, "site.json"))
+	if err != nil {
+		log.Fatalf("Failed to open site store: %v", err)
+	}
+
+	// 直接组装渲染存储，不启动服务器：NewServer 会先补齐未渲染的文章，随后又全部重渲染一遍
+	render := web.NewRenderer(cfg, store, siteStore)
+
+	start := time.Now()
+	n, err := blog.NewRenderingStore(store, render).RerenderAll()
+	if err != nil {
+		log.Fatalf("Re-render failed after %d post(s): %v", n, err)
+	}
+	pages, err := blog.NewRenderingPageStore(store, render).RerenderAll()
+	if err != nil {
+		log.Fatalf("Re-render failed after %d page(s): %v", pages, err)
+	}
+	fmt.Printf("Re-rendered %d post(s) and %d page(s) in %s.\n", n, pages, time.Since(start).Round(time.Millisecond))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"path/filepath"
@@ -12,6 +13,11 @@ import (
 
 func main() {
 	cfg := config.Load()
+	flag.BoolVar(&cfg.Dev, "dev", cfg.Dev, "Read templates and static files from disk and reload them on change")
+	flag.Parse()
+	if cfg.Dev {
+		log.Println("Dev mode: serving templates and static files from disk")
+	}
 
 	store, err := blog.NewSQLiteStore(filepath.Join(cfg.DataDir, "blog.db"))
 	if err != nil {
@@ -39,7 +45,8 @@ func main() {
 		log.Fatal(err)
 	}
 
-	server := web.NewServer(cfg, store, siteStore)
+	// 相关文章索引在启动时构建，之后随写操作增量更新
+	server := web.NewServer(cfg, blog.NewRelatedStore(store), store, store, siteStore)
 
 	// 合并公开路由和管理路由到同一个服务器
 	// Fly.io 只支持单端口，管理后台通过 /admin/* 路径访问
@@ -110,6 +110,14 @@ func (s *FileStore) Update(slug string, updated Post) error {
 	updated.UpdatedAt = time.Now()
 
 	s.posts[index] = updated
+	if updated.Slug != slug {
+		// 原文改了 slug，译文跟着指向新 slug
+		for i := range s.posts {
+			if s.posts[i].TranslationOf == slug {
+				s.posts[i].TranslationOf = updated.Slug
+			}
+		}
+	}
 	return s.save()
 }
 
@@ -136,6 +144,19 @@ func (s *FileStore) Delete(slug string) error {
 	return s.save()
 }
 
+func (s *FileStore) SaveRendered(slug string, rendered Rendered) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.posts {
+		if s.posts[i].Slug == slug {
+			s.posts[i].applyRendered(rendered, time.Now())
+			return s.save()
+		}
+	}
+	return ErrNotFound
+}
+
 func (s *FileStore) load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -251,6 +272,7 @@ func (s *FileStore) ListPublishedPaginated(page, pageSize int) ([]Post, int) {
 	return published[start:end], total
 }
 
+// GetRelated ranks published posts by shared tags, then same category, then recency.
 func (s *FileStore) GetRelated(slug string, n int) []Post {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -264,13 +286,14 @@ func (s *FileStore) GetRelated(slug string, n int) []Post {
 			break
 		}
 	}
-	if !found || len(current.Tags) == 0 {
+	if !found || n <= 0 {
 		return []Post{}
 	}
 
 	type scoredPost struct {
-		post  Post
-		score int
+		post         Post
+		score        int
+		sameCategory bool
 	}
 
 	var candidates []scoredPost
@@ -291,15 +314,20 @@ func (s *FileStore) GetRelated(slug string, n int) []Post {
 			}
 		}
 
-		if score > 0 {
-			candidates = append(candidates, scoredPost{post: p, score: score})
-		}
+		candidates = append(candidates, scoredPost{
+			post:         p,
+			score:        score,
+			sameCategory: current.Category != "" && p.Category == current.Category,
+		})
 	}
 
 	sort.Slice(candidates, func(i, j int) bool {
 		if candidates[i].score != candidates[j].score {
 			return candidates[i].score > candidates[j].score
 		}
+		if candidates[i].sameCategory != candidates[j].sameCategory {
+			return candidates[i].sameCategory
+		}
 		return candidates[i].post.CreatedAt.After(candidates[j].post.CreatedAt)
 	})
 
@@ -313,3 +341,127 @@ func (s *FileStore) GetRelated(slug string, n int) []Post {
 	}
 	return result
 }
+
+func (s *FileStore) PrevPost(slug string) (Post, bool) {
+	return s.adjacentPost(slug, 1)
+}
+
+func (s *FileStore) NextPost(slug string) (Post, bool) {
+	return s.adjacentPost(slug, -1)
+}
+
+// adjacentPost walks the published list, which is sorted newest first, so
+// step 1 moves to an older post and -1 to a newer one.
+func (s *FileStore) adjacentPost(slug string, step int) (Post, bool) {
+	current, ok := s.GetBySlug(slug)
+	if !ok {
+		return Post{}, false
+	}
+	published := s.ListPublished()
+	sort.SliceStable(published, func(i, j int) bool {
+		if published[i].CreatedAt.Equal(published[j].CreatedAt) {
+			return published[i].Slug > published[j].Slug
+		}
+		return published[i].CreatedAt.After(published[j].CreatedAt)
+	})
+	// 草稿不在列表中，按时间找到它应处的位置
+	index := sort.Search(len(published), func(i int) bool {
+		p := published[i]
+		return p.CreatedAt.Before(current.CreatedAt) ||
+			(p.CreatedAt.Equal(current.CreatedAt) && p.Slug <= current.Slug)
+	})
+	if index < len(published) && published[index].Slug == slug {
+		index += step
+	} else if step < 0 {
+		index--
+	}
+	if index < 0 || index >= len(published) {
+		return Post{}, false
+	}
+	return published[index], true
+}
+
+func (s *FileStore) ListByTag(tag string) []Post {
+	var result []Post
+	for _, p := range s.ListPublished() {
+		for _, t := range p.Tags {
+			if t == tag {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (s *FileStore) ListByCategory(category string) []Post {
+	var result []Post
+	for _, p := range s.ListPublished() {
+		if p.Category == category {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (s *FileStore) ListBySeries(series string) []Post {
+	var result []Post
+	for _, p := range s.ListPublished() {
+		if series != "" && p.Series == series {
+			result = append(result, p)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].SeriesOrder != result[j].SeriesOrder {
+			return result[i].SeriesOrder < result[j].SeriesOrder
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (s *FileStore) ListTranslations(group string) []Post {
+	var result []Post
+	for _, p := range s.ListPublished() {
+		if group != "" && (p.Slug == group || p.TranslationOf == group) {
+			result = append(result, p)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Lang < result[j].Lang
+	})
+	return result
+}
+
+func (s *FileStore) TagCounts() []TermCount {
+	counts := map[string]int{}
+	for _, p := range s.ListPublished() {
+		for _, t := range p.Tags {
+			if t != "" {
+				counts[t]++
+			}
+		}
+	}
+	return sortedTermCounts(counts)
+}
+
+func (s *FileStore) CategoryCounts() []TermCount {
+	counts := map[string]int{}
+	for _, p := range s.ListPublished() {
+		if p.Category != "" {
+			counts[p.Category]++
+		}
+	}
+	return sortedTermCounts(counts)
+}
+
+func sortedTermCounts(counts map[string]int) []TermCount {
+	terms := make([]TermCount, 0, len(counts))
+	for name, n := range counts {
+		terms = append(terms, TermCount{Name: name, Count: n})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].Name < terms[j].Name
+	})
+	return terms
+}
@@ -7,7 +7,22 @@ type Store interface {
 	ListPublishedPaginated(page, pageSize int) ([]Post, int)
 	GetBySlug(slug string) (Post, bool)
 	GetRelated(slug string, n int) []Post
+	// PrevPost and NextPost return the published posts created just before
+	// and just after slug.
+	PrevPost(slug string) (Post, bool)
+	NextPost(slug string) (Post, bool)
+	ListByTag(tag string) []Post
+	ListByCategory(category string) []Post
+	// ListBySeries returns the published posts of a series in reading order.
+	ListBySeries(series string) []Post
+	// ListTranslations returns the published posts of a translation group:
+	// the original post and every post that is a translation of it.
+	ListTranslations(group string) []Post
+	TagCounts() []TermCount
+	CategoryCounts() []TermCount
 	Create(post Post) error
 	Update(slug string, post Post) error
 	Delete(slug string) error
+	// SaveRendered stores pre-rendered content without touching UpdatedAt.
+	SaveRendered(slug string, rendered Rendered) error
 }
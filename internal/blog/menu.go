@@ -0,0 +1,50 @@
+package blog
+
+import "strings"
+
+// Menu names rendered by the site templates.
+const (
+	MenuHeader = "header"
+	MenuFooter = "footer"
+)
+
+// MenuItem is one link in a navigation menu. URL is either root-relative
+// ("/posts", "/#about") for pages on this site, or an absolute http(s) or
+// mailto: link. Items may nest to build dropdowns.
+type MenuItem struct {
+	Name     string     `json:"name"`
+	URL      string     `json:"url"`
+	Children []MenuItem `json:"children,omitempty"`
+
+	// Key 是界面文案的消息键，设置后按访客语言显示译文而不是 Name；
+	// 只有默认菜单使用，后台保存的菜单按原样显示
+	Key string `json:"key,omitempty"`
+}
+
+// IsExternal reports whether the item links away from this site.
+func (i MenuItem) IsExternal() bool {
+	u := strings.ToLower(i.URL)
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "mailto:")
+}
+
+// DefaultMenus mirrors the links the header had before menus were configurable.
+// The names are translated for each visitor through their message keys.
+func DefaultMenus() map[string][]MenuItem {
+	return map[string][]MenuItem{
+		MenuHeader: {
+			{Name: "文章", URL: "/posts", Key: "menu.posts"},
+			{Name: "归档", URL: "/archive", Key: "menu.archive"},
+			{Name: "关于", URL: "/#about", Key: "menu.about"},
+		},
+		MenuFooter: {},
+	}
+}
+
+// Menu returns the named menu, falling back to the default when the profile
+// has never configured it.
+func (p SiteProfile) Menu(name string) []MenuItem {
+	if items, ok := p.Menus[name]; ok {
+		return items
+	}
+	return DefaultMenus()[name]
+}
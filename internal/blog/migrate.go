@@ -0,0 +1,197 @@
+package blog
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 迁移脚本随二进制一起发布，文件名格式为 0001_name.sql，按版本号升序执行
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations returns all embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		rawVersion, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		if prev, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, prev, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		data, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate applies all pending migrations, each in its own transaction,
+// and returns how many were applied.
+func (s *SQLiteStore) Migrate() (int, error) {
+	if err := s.ensureSchemaVersionTable(); err != nil {
+		return 0, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := s.appliedVersions()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return count, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrationStatus reports every known migration and whether it has been applied.
+// It only reads the database: without a schema_version table every migration
+// is reported as pending.
+func (s *SQLiteStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	exists, err := s.hasSchemaVersionTable()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = s.appliedVersions(); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = at
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for a fresh database.
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	exists, err := s.hasSchemaVersionTable()
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := s.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// hasSchemaVersionTable reports whether any migration run has created schema_version yet.
+func (s *SQLiteStore) hasSchemaVersionTable() (bool, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&n)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) ensureSchemaVersionTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);
+	`)
+	return err
+}
+
+func (s *SQLiteStore) appliedVersions() (map[int]time.Time, error) {
+	rows, err := s.db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (s *SQLiteStore) applyMigration(m Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Commit 成功后 Rollback 为空操作
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,118 @@
+package blog
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "blog.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, m := range migrations {
+		// 版本号严格递增；删掉的迁移留下的空号不再复用
+		if m.Version <= 0 || (i > 0 && m.Version <= migrations[i-1].Version) {
+			t.Errorf("migration %d has version %d, not after %d", i, m.Version, migrations[max(i-1, 0)].Version)
+		}
+		if m.Name == "" || m.SQL == "" {
+			t.Errorf("migration %04d has an empty name or script", m.Version)
+		}
+	}
+}
+
+func TestMigrateIdempotent(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	s := openTestStore(t)
+	steps := []struct {
+		name        string
+		wantApplied int
+	}{
+		{"fresh database", len(migrations)},
+		{"second run", 0},
+		{"third run", 0},
+	}
+	for _, step := range steps {
+		n, err := s.Migrate()
+		if err != nil {
+			t.Fatalf("%s: Migrate: %v", step.name, err)
+		}
+		if n != step.wantApplied {
+			t.Errorf("%s: applied %d migrations, want %d", step.name, n, step.wantApplied)
+		}
+		version, err := s.SchemaVersion()
+		if err != nil {
+			t.Fatalf("%s: SchemaVersion: %v", step.name, err)
+		}
+		if version != latest {
+			t.Errorf("%s: schema version %d, want %d", step.name, version, latest)
+		}
+	}
+
+	statuses, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, st := range statuses {
+		if !st.Applied || st.AppliedAt.IsZero() {
+			t.Errorf("migration %04d_%s not recorded as applied", st.Version, st.Name)
+		}
+	}
+}
+
+func TestMigrationStatusReadOnly(t *testing.T) {
+	s := openTestStore(t)
+
+	statuses, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, st := range statuses {
+		if st.Applied {
+			t.Errorf("migration %04d_%s reported as applied on an empty database", st.Version, st.Name)
+		}
+	}
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("schema version %d, want 0", version)
+	}
+	exists, err := s.hasSchemaVersionTable()
+	if err != nil {
+		t.Fatalf("hasSchemaVersionTable: %v", err)
+	}
+	if exists {
+		t.Error("status created the schema_version table")
+	}
+}
+
+func TestNewSQLiteStoreReopen(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "blog.db")
+	for i := 0; i < 2; i++ {
+		s, err := NewSQLiteStore(dsn)
+		if err != nil {
+			t.Fatalf("open %d: %v", i+1, err)
+		}
+		s.Close()
+	}
+}
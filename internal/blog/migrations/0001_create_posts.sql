@@ -0,0 +1,15 @@
+-- 初始结构：与早期 SQLiteStore.init 创建的表保持一致，已有数据库上重复执行无副作用
+CREATE TABLE IF NOT EXISTS posts (
+	slug TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	summary TEXT,
+	content TEXT,
+	category TEXT,
+	tags TEXT,
+	cover_image TEXT,
+	featured BOOLEAN,
+	is_draft BOOLEAN,
+	created_at DATETIME,
+	updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at DESC);
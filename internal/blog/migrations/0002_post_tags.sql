@@ -0,0 +1,16 @@
+-- 标签拆分到独立的关联表，按标签查询时可以走索引
+CREATE TABLE IF NOT EXISTS post_tags (
+	post_slug TEXT NOT NULL REFERENCES posts(slug) ON UPDATE CASCADE ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (post_slug, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag ON post_tags(tag);
+
+-- 迁移旧的 JSON 标签列
+INSERT OR IGNORE INTO post_tags (post_slug, tag, position)
+SELECT posts.slug, TRIM(j.value), j.key
+FROM posts, json_each(CASE WHEN json_valid(posts.tags) THEN posts.tags ELSE '[]' END) AS j
+WHERE TRIM(j.value) != '';
+
+ALTER TABLE posts DROP COLUMN tags;
@@ -0,0 +1,7 @@
+-- 保存时预渲染的 HTML、纯文本与派生信息，避免每次请求都重新渲染 Markdown
+ALTER TABLE posts ADD COLUMN content_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN content_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN word_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN outline TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE posts ADD COLUMN first_image TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN rendered_at DATETIME;
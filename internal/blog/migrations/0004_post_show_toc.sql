@@ -0,0 +1,5 @@
+-- 每篇文章可单独关闭目录，已有文章默认显示
+ALTER TABLE posts ADD COLUMN show_toc BOOLEAN NOT NULL DEFAULT 1;
+
+-- 标题 ID 规则随本次升级变化，清空渲染时间让启动时自动重新渲染
+UPDATE posts SET rendered_at = NULL;
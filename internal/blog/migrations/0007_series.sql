@@ -0,0 +1,12 @@
+-- 系列：多篇文章按顺序组成一组，成员关系与顺序记录在文章上
+CREATE TABLE IF NOT EXISTS series (
+	slug TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at DATETIME,
+	updated_at DATETIME
+);
+
+ALTER TABLE posts ADD COLUMN series_slug TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN series_order INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_posts_series ON posts(series_slug, series_order);
@@ -0,0 +1,13 @@
+-- 独立页面（关于、Now、项目等），按 path 直接挂在站点根路径下
+CREATE TABLE IF NOT EXISTS pages (
+	path TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	summary TEXT NOT NULL DEFAULT '',
+	content TEXT NOT NULL DEFAULT '',
+	template TEXT NOT NULL DEFAULT '',
+	show_in_nav BOOLEAN NOT NULL DEFAULT 0,
+	nav_order INTEGER NOT NULL DEFAULT 0,
+	is_draft BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME,
+	updated_at DATETIME
+);
@@ -0,0 +1,4 @@
+-- 文章语言与译文关系：译文的 translation_of 指向原文的 slug
+ALTER TABLE posts ADD COLUMN lang TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN translation_of TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_posts_translation_of ON posts(translation_of);
@@ -0,0 +1,3 @@
+-- 独立页面与文章一样保存预渲染的 HTML，已有页面在启动时补齐
+ALTER TABLE pages ADD COLUMN content_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE pages ADD COLUMN rendered_at DATETIME;
@@ -0,0 +1,3 @@
+-- 记录渲染时的渲染器版本，版本号变化后启动时自动重新渲染，不再需要只清空 rendered_at 的迁移
+ALTER TABLE posts ADD COLUMN renderer_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE pages ADD COLUMN renderer_version INTEGER NOT NULL DEFAULT 0;
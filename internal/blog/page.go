@@ -0,0 +1,161 @@
+package blog
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrPageNotFound = errors.New("page not found")
+var ErrDuplicatePage = errors.New("page path already exists")
+
+// Page is a standalone Markdown page such as About or Now, served at
+// /{Path} outside the post list.
+type Page struct {
+	Path    string `json:"path"`
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Content string `json:"content"`
+	// Template 为空时使用默认页面模板
+	Template  string    `json:"template"`
+	ShowInNav bool      `json:"show_in_nav"`
+	NavOrder  int       `json:"nav_order"`
+	IsDraft   bool      `json:"is_draft"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 以下字段在保存时由 Content 渲染得到，不需要手动填写
+	ContentHTML string    `json:"content_html,omitempty"`
+	RenderedAt  time.Time `json:"rendered_at,omitempty"`
+	// 渲染时的 RendererVersion，与当前版本不同的会在启动时重新渲染
+	RendererVersion int `json:"renderer_version,omitempty"`
+}
+
+// PageStore manages standalone pages.
+type PageStore interface {
+	// ListPages returns all pages ordered by nav order, then title.
+	ListPages() []Page
+	GetPage(path string) (Page, bool)
+	CreatePage(page Page) error
+	UpdatePage(path string, page Page) error
+	DeletePage(path string) error
+	// SavePageRendered stores pre-rendered HTML without touching UpdatedAt.
+	SavePageRendered(path, html string) error
+}
+
+func (p *Page) applyRendered(html string, at time.Time) {
+	p.ContentHTML = html
+	p.RenderedAt = at
+	p.RendererVersion = RendererVersion
+}
+
+const selectPages = "SELECT path, title, summary, content, template, show_in_nav, nav_order, is_draft, created_at, updated_at, content_html, rendered_at, renderer_version FROM pages"
+
+func (s *SQLiteStore) ListPages() []Page {
+	rows, err := s.db.Query(selectPages + " ORDER BY nav_order, title")
+	if err != nil {
+		log.Printf("sqlite: query pages: %v", err)
+		return []Page{}
+	}
+	defer rows.Close()
+
+	var pages []Page
+	for rows.Next() {
+		page, err := scanPage(rows)
+		if err != nil {
+			log.Printf("sqlite: scan page: %v", err)
+			continue
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func (s *SQLiteStore) GetPage(path string) (Page, bool) {
+	page, err := scanPage(s.db.QueryRow(selectPages+" WHERE path = ?", path))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("sqlite: get page %s: %v", path, err)
+		}
+		return Page{}, false
+	}
+	return page, true
+}
+
+func (s *SQLiteStore) CreatePage(page Page) error {
+	if page.Path == "" {
+		return ErrInvalidSlug
+	}
+	if s.count("SELECT COUNT(*) FROM pages WHERE path = ?", page.Path) > 0 {
+		return ErrDuplicatePage
+	}
+	now := time.Now()
+	page.CreatedAt = now
+	page.UpdatedAt = now
+	_, err := s.db.Exec(`
+	INSERT INTO pages (path, title, summary, content, template, show_in_nav, nav_order, is_draft, created_at, updated_at, content_html, rendered_at, renderer_version)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		page.Path, page.Title, page.Summary, page.Content, page.Template, page.ShowInNav, page.NavOrder, page.IsDraft, page.CreatedAt, page.UpdatedAt,
+		page.ContentHTML, nullTime(page.RenderedAt), page.RendererVersion)
+	return err
+}
+
+func (s *SQLiteStore) UpdatePage(path string, page Page) error {
+	if page.Path == "" {
+		page.Path = path
+	}
+	if page.Path != path && s.count("SELECT COUNT(*) FROM pages WHERE path = ?", page.Path) > 0 {
+		return ErrDuplicatePage
+	}
+	res, err := s.db.Exec(`
+	UPDATE pages SET
+		path = ?, title = ?, summary = ?, content = ?, template = ?,
+		show_in_nav = ?, nav_order = ?, is_draft = ?, updated_at = ?,
+		content_html = ?, rendered_at = ?, renderer_version = ?
+	WHERE path = ?`,
+		page.Path, page.Title, page.Summary, page.Content, page.Template, page.ShowInNav, page.NavOrder, page.IsDraft, time.Now(),
+		page.ContentHTML, nullTime(page.RenderedAt), page.RendererVersion, path)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrPageNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeletePage(path string) error {
+	_, err := s.db.Exec("DELETE FROM pages WHERE path = ?", path)
+	return err
+}
+
+func (s *SQLiteStore) SavePageRendered(path, html string) error {
+	res, err := s.db.Exec("UPDATE pages SET content_html = ?, rendered_at = ?, renderer_version = ? WHERE path = ?", html, time.Now(), RendererVersion, path)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrPageNotFound
+	}
+	return nil
+}
+
+func scanPage(row rowScanner) (Page, error) {
+	var p Page
+	var showInNav, isDraft sql.NullBool
+	var navOrder sql.NullInt64
+	var createdAt, updatedAt, renderedAt sql.NullTime
+	err := row.Scan(&p.Path, &p.Title, &p.Summary, &p.Content, &p.Template,
+		&showInNav, &navOrder, &isDraft, &createdAt, &updatedAt, &p.ContentHTML, &renderedAt, &p.RendererVersion)
+	if err != nil {
+		return Page{}, err
+	}
+	p.ShowInNav = showInNav.Bool
+	p.NavOrder = int(navOrder.Int64)
+	p.IsDraft = isDraft.Bool
+	p.CreatedAt = createdAt.Time
+	p.UpdatedAt = updatedAt.Time
+	p.RenderedAt = renderedAt.Time
+	return p, nil
+}
@@ -1,8 +1,9 @@
 package blog
 
 import (
-	"fmt"
+	"math"
 	"time"
+	"unicode"
 )
 
 type Post struct {
@@ -15,16 +16,112 @@ type Post struct {
 	CoverImage string    `json:"cover_image"`
 	Featured   bool      `json:"featured"`
 	IsDraft    bool      `json:"is_draft"`
+	ShowTOC    bool      `json:"show_toc"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+
+	// 所属系列的 slug 与在系列中的序号（从 1 开始）
+	Series      string `json:"series,omitempty"`
+	SeriesOrder int    `json:"series_order,omitempty"`
+
+	// 文章语言（BCP 47，例如 en、zh-CN），为空表示站点默认语言；
+	// 译文的 TranslationOf 是原文的 slug
+	Lang          string `json:"lang,omitempty"`
+	TranslationOf string `json:"translation_of,omitempty"`
+
+	// 以下字段在保存时由 Content 渲染得到，不需要手动填写
+	ContentHTML string    `json:"content_html,omitempty"`
+	ContentText string    `json:"content_text,omitempty"`
+	WordCount   int       `json:"word_count,omitempty"`
+	Outline     []Heading `json:"outline,omitempty"`
+	FirstImage  string    `json:"first_image,omitempty"`
+	RenderedAt  time.Time `json:"rendered_at,omitempty"`
+	// 渲染时的 RendererVersion，与当前版本不同的会在启动时重新渲染
+	RendererVersion int `json:"renderer_version,omitempty"`
+}
+
+// Heading is one entry of a post's heading outline.
+type Heading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// Rendered is the output of rendering a post's Markdown content.
+type Rendered struct {
+	HTML       string
+	Text       string
+	WordCount  int
+	Outline    []Heading
+	FirstImage string
+}
+
+// RenderFunc turns a post's Markdown into stored HTML and derived metadata.
+type RenderFunc func(post Post) Rendered
+
+func (p *Post) applyRendered(r Rendered, at time.Time) {
+	p.ContentHTML = r.HTML
+	p.ContentText = r.Text
+	p.WordCount = r.WordCount
+	p.Outline = r.Outline
+	p.FirstImage = r.FirstImage
+	p.RenderedAt = at
+	p.RendererVersion = RendererVersion
+}
+
+// TermCount is a tag or category name with the number of published posts using it.
+type TermCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// 阅读速度：英文按单词计，中日韩文字按字计
+const (
+	latinWordsPerMinute = 200
+	cjkCharsPerMinute   = 400
+)
+
+// ReadMinutes estimates reading time in whole minutes (at least one) from
+// the rendered plain text, counting Latin words and CJK characters separately.
+func (p Post) ReadMinutes() int {
+	text := p.ContentText
+	if text == "" {
+		text = p.Content
+	}
+	latin, cjk := CountWords(text)
+	minutes := int(math.Round(float64(latin)/latinWordsPerMinute + float64(cjk)/cjkCharsPerMinute))
+	return max(minutes, 1)
+}
+
+// TranslationGroup is the slug shared by a post and its translations: the
+// original's slug.
+func (p Post) TranslationGroup() string {
+	if p.TranslationOf != "" {
+		return p.TranslationOf
+	}
+	return p.Slug
 }
 
-func (p Post) ReadTime() string {
-	runes := []rune(p.Content)
-	count := len(runes)
-	minutes := count / 400
-	if minutes < 1 {
-		return "1 分钟"
+// CountWords counts Latin words and CJK characters separately.
+func CountWords(text string) (latin, cjk int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				latin++
+				inWord = true
+			}
+		default:
+			// 撇号与连字符不打断单词，例如 don't、go-routine
+			if inWord && (r == '\'' || r == '-' || r == '_') {
+				continue
+			}
+			inWord = false
+		}
 	}
-	return fmt.Sprintf("%d 分钟", minutes)
+	return latin, cjk
 }
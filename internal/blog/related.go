@@ -0,0 +1,328 @@
+package blog
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// 相关文章综合得分的权重：正文相似度为主，标签重合与新近程度为辅
+const (
+	relatedWeightText    = 0.65
+	relatedWeightTags    = 0.25
+	relatedWeightRecency = 0.10
+
+	// 新近程度按文章年龄衰减，半衰期约为 90 天
+	relatedRecencyHalfLife = 90 * 24 * time.Hour
+)
+
+// 标题和摘要比正文更能代表主题，计入词频时重复若干次
+const (
+	relatedTitleBoost   = 3
+	relatedSummaryBoost = 2
+)
+
+var relatedStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"are": true, "was": true, "you": true, "your": true, "from": true, "not": true,
+	"but": true, "can": true, "use": true, "how": true, "what": true, "its": true,
+	"http": true, "https": true, "www": true, "com": true, "png": true, "jpg": true,
+	"uploads": true, "img": true,
+}
+
+type relatedDoc struct {
+	post Post
+	tf   map[string]float64
+	vec  map[string]float64
+}
+
+// RelatedEngine keeps TF-IDF vectors of title, summary and content for every post
+// and ranks related posts by cosine similarity, tag overlap and recency.
+// Posts are re-tokenized only when they change; IDF weights are refreshed lazily.
+type RelatedEngine struct {
+	mu    sync.RWMutex
+	docs  map[string]*relatedDoc
+	df    map[string]int
+	dirty bool
+}
+
+func NewRelatedEngine() *RelatedEngine {
+	return &RelatedEngine{
+		docs: map[string]*relatedDoc{},
+		df:   map[string]int{},
+	}
+}
+
+// Rebuild replaces the whole index with the given posts.
+func (e *RelatedEngine) Rebuild(posts []Post) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.docs = map[string]*relatedDoc{}
+	e.df = map[string]int{}
+	for _, p := range posts {
+		e.upsertLocked(p)
+	}
+	e.dirty = true
+}
+
+// Upsert adds or refreshes a single post.
+func (e *RelatedEngine) Upsert(post Post) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.upsertLocked(post)
+	e.dirty = true
+}
+
+// Remove drops a post from the index.
+func (e *RelatedEngine) Remove(slug string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removeLocked(slug)
+	e.dirty = true
+}
+
+// Related returns up to n published posts most similar to slug.
+func (e *RelatedEngine) Related(slug string, n int) []Post {
+	e.refreshVectors()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	current, ok := e.docs[slug]
+	if !ok || n <= 0 {
+		return []Post{}
+	}
+
+	currentTags := map[string]bool{}
+	for _, t := range current.post.Tags {
+		currentTags[strings.ToLower(t)] = true
+	}
+
+	var newest time.Time
+	for _, d := range e.docs {
+		if !d.post.IsDraft && d.post.CreatedAt.After(newest) {
+			newest = d.post.CreatedAt
+		}
+	}
+
+	type scoredPost struct {
+		post  Post
+		score float64
+	}
+	var candidates []scoredPost
+	for other, d := range e.docs {
+		if other == slug || d.post.IsDraft {
+			continue
+		}
+		score := relatedWeightText*cosine(current.vec, d.vec) +
+			relatedWeightTags*tagOverlap(currentTags, d.post.Tags) +
+			relatedWeightRecency*recency(newest, d.post.CreatedAt)
+		candidates = append(candidates, scoredPost{post: d.post, score: score})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].post.CreatedAt.After(candidates[j].post.CreatedAt)
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	result := make([]Post, 0, len(candidates))
+	for _, c := range candidates {
+		result = append(result, c.post)
+	}
+	return result
+}
+
+func (e *RelatedEngine) upsertLocked(post Post) {
+	e.removeLocked(post.Slug)
+
+	tf := termFrequencies(post)
+	for term := range tf {
+		e.df[term]++
+	}
+	// 正文只用于计算向量，索引中不再保留，避免常驻内存
+	post.Content = ""
+	post.ContentHTML = ""
+	post.ContentText = ""
+	e.docs[post.Slug] = &relatedDoc{post: post, tf: tf}
+}
+
+func (e *RelatedEngine) removeLocked(slug string) {
+	old, ok := e.docs[slug]
+	if !ok {
+		return
+	}
+	for term := range old.tf {
+		e.df[term]--
+		if e.df[term] <= 0 {
+			delete(e.df, term)
+		}
+	}
+	delete(e.docs, slug)
+}
+
+// refreshVectors recomputes normalized TF-IDF vectors after any change,
+// since a single write shifts the IDF of every shared term.
+func (e *RelatedEngine) refreshVectors() {
+	e.mu.RLock()
+	dirty := e.dirty
+	e.mu.RUnlock()
+	if !dirty {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.dirty {
+		return
+	}
+
+	total := float64(len(e.docs))
+	for _, d := range e.docs {
+		vec := make(map[string]float64, len(d.tf))
+		var norm float64
+		for term, freq := range d.tf {
+			idf := math.Log((total+1)/float64(e.df[term]+1)) + 1
+			w := freq * idf
+			vec[term] = w
+			norm += w * w
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for term := range vec {
+				vec[term] /= norm
+			}
+		}
+		d.vec = vec
+	}
+	e.dirty = false
+}
+
+func termFrequencies(post Post) map[string]float64 {
+	counts := map[string]int{}
+	add := func(text string, boost int) {
+		for _, tok := range Tokenize(text) {
+			counts[tok] += boost
+		}
+	}
+	add(post.Title, relatedTitleBoost)
+	add(post.Summary, relatedSummaryBoost)
+	// 优先使用渲染后的纯文本，避免 Markdown 语法和链接地址干扰
+	if post.ContentText != "" {
+		add(post.ContentText, 1)
+	} else {
+		add(post.Content, 1)
+	}
+
+	tf := make(map[string]float64, len(counts))
+	for term, n := range counts {
+		// 对数词频，避免长文中的高频词压倒其它特征
+		tf[term] = 1 + math.Log(float64(n))
+	}
+	return tf
+}
+
+// Tokenize splits text into lowercase Latin words and CJK bigrams.
+// A run of CJK characters "并发编程" yields "并发", "发编", "编程";
+// a single isolated CJK character is kept as a unigram.
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) >= 2 {
+			w := string(word)
+			if !relatedStopwords[w] {
+				tokens = append(tokens, w)
+			}
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func cosine(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var dot float64
+	for term, w := range a {
+		dot += w * b[term]
+	}
+	return dot
+}
+
+// tagOverlap is the Jaccard index of two tag sets, compared case-insensitively.
+func tagOverlap(current map[string]bool, tags []string) float64 {
+	if len(current) == 0 || len(tags) == 0 {
+		return 0
+	}
+	shared := 0
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(t)
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if current[t] {
+			shared++
+		}
+	}
+	union := len(current) + len(seen) - shared
+	return float64(shared) / float64(union)
+}
+
+func recency(newest, created time.Time) float64 {
+	if newest.IsZero() || created.IsZero() {
+		return 0
+	}
+	age := newest.Sub(created)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(relatedRecencyHalfLife))
+}
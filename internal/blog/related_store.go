@@ -0,0 +1,76 @@
+package blog
+
+// RelatedStore wraps a Store and answers GetRelated from a RelatedEngine,
+// keeping the engine in sync with every successful write.
+type RelatedStore struct {
+	Store
+	engine *RelatedEngine
+}
+
+func NewRelatedStore(store Store) *RelatedStore {
+	engine := NewRelatedEngine()
+	engine.Rebuild(store.List())
+	return &RelatedStore{Store: store, engine: engine}
+}
+
+func (s *RelatedStore) Engine() *RelatedEngine {
+	return s.engine
+}
+
+func (s *RelatedStore) GetRelated(slug string, n int) []Post {
+	return s.engine.Related(slug, n)
+}
+
+func (s *RelatedStore) Create(post Post) error {
+	if err := s.Store.Create(post); err != nil {
+		return err
+	}
+	s.refresh(post.Slug)
+	return nil
+}
+
+func (s *RelatedStore) Update(slug string, post Post) error {
+	if err := s.Store.Update(slug, post); err != nil {
+		return err
+	}
+	newSlug := post.Slug
+	if newSlug == "" {
+		newSlug = slug
+	}
+	if newSlug != slug {
+		s.engine.Remove(slug)
+	}
+	s.refresh(newSlug)
+	return nil
+}
+
+func (s *RelatedStore) Delete(slug string) error {
+	if err := s.Store.Delete(slug); err != nil {
+		return err
+	}
+	s.engine.Remove(slug)
+	return nil
+}
+
+func (s *RelatedStore) SaveRendered(slug string, rendered Rendered) error {
+	if err := s.Store.SaveRendered(slug, rendered); err != nil {
+		return err
+	}
+	s.refresh(slug)
+	return nil
+}
+
+// Refresh re-reads posts that were changed without going through the store,
+// such as the members of a renamed series.
+func (s *RelatedStore) Refresh(slugs ...string) {
+	for _, slug := range slugs {
+		s.refresh(slug)
+	}
+}
+
+// refresh 重新读取文章，以拿到底层存储补全的时间戳
+func (s *RelatedStore) refresh(slug string) {
+	if post, ok := s.Store.GetBySlug(slug); ok {
+		s.engine.Upsert(post)
+	}
+}
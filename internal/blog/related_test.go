@@ -0,0 +1,126 @@
+package blog
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"CJK bigrams", "并发编程", []string{"并发", "发编", "编程"}},
+		{"isolated CJK character", "a 字 b", []string{"字"}},
+		{"punctuation splits CJK runs", "并发，编程", []string{"并发", "编程"}},
+		{"Latin words are lowercased", "Go Rust", []string{"go", "rust"}},
+		{"stopwords and single letters dropped", "The a and Rust", []string{"rust"}},
+		{"mixed scripts", "Go1.24并发", []string{"go1", "24", "并发"}},
+		{"kana", "カタカナ", []string{"カタ", "タカ", "カナ"}},
+		{"hangul", "한국어", []string{"한국", "국어"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagOverlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []string
+		tags    []string
+		want    float64
+	}{
+		{"no tags", nil, []string{"go"}, 0},
+		{"identical", []string{"go", "并发"}, []string{"go", "并发"}, 1},
+		{"case-insensitive", []string{"go"}, []string{"Go"}, 1},
+		{"half shared", []string{"go", "rust"}, []string{"go", "css"}, 1.0 / 3},
+		{"duplicates counted once", []string{"go"}, []string{"go", "GO"}, 1},
+		{"disjoint", []string{"go"}, []string{"css"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := map[string]bool{}
+			for _, tag := range tt.current {
+				current[tag] = true
+			}
+			if got := tagOverlap(current, tt.tags); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("tagOverlap(%v, %v) = %v, want %v", tt.current, tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelatedEngineRanking(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts := []Post{
+		{Slug: "go-concurrency", Title: "Go 并发编程", Content: "goroutine channel 并发编程 调度器", Tags: []string{"Go"}, CreatedAt: day},
+		{Slug: "go-patterns", Title: "Go 并发模式", Content: "goroutine channel 并发模式 管道", Tags: []string{"Go"}, CreatedAt: day},
+		{Slug: "go-draft", Title: "Go 并发编程草稿", Content: "goroutine channel 并发编程 调度器", Tags: []string{"Go"}, CreatedAt: day, IsDraft: true},
+		{Slug: "go-modules", Title: "Go 模块管理", Content: "module version 依赖", Tags: []string{"Go"}, CreatedAt: day},
+		{Slug: "css-grid", Title: "CSS 网格布局", Content: "grid layout 响应式", Tags: []string{"CSS"}, CreatedAt: day},
+		{Slug: "css-old", Title: "CSS 网格布局", Content: "grid layout 响应式", Tags: []string{"CSS"}, CreatedAt: day.AddDate(-2, 0, 0)},
+	}
+	e := NewRelatedEngine()
+	e.Rebuild(posts)
+
+	tests := []struct {
+		name string
+		slug string
+		n    int
+		want []string
+	}{
+		// 正文最接近的排在前面，草稿不出现；只有标签相同的排在无关文章前
+		{"text then tags", "go-concurrency", 3, []string{"go-patterns", "go-modules", "css-grid"}},
+		{"limit", "go-concurrency", 1, []string{"go-patterns"}},
+		// 内容完全相同时较新的文章得分更高
+		{"recency breaks ties", "go-modules", 5, []string{"go-concurrency", "go-patterns", "css-grid", "css-old"}},
+		{"unknown slug", "missing", 3, []string{}},
+		{"zero limit", "go-concurrency", 0, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relatedSlugs(e.Related(tt.slug, tt.n)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Related(%q, %d) = %q, want %q", tt.slug, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelatedEngineUpdates(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewRelatedEngine()
+	e.Rebuild([]Post{
+		{Slug: "a", Title: "Go 并发编程", Content: "goroutine channel", CreatedAt: day},
+		{Slug: "b", Title: "Go 并发编程", Content: "goroutine channel", CreatedAt: day},
+		{Slug: "c", Title: "CSS 布局", Content: "grid layout", CreatedAt: day},
+	})
+	if got := relatedSlugs(e.Related("a", 1)); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("before update: %q", got)
+	}
+
+	e.Upsert(Post{Slug: "c", Title: "Go 并发编程", Content: "goroutine channel select", CreatedAt: day.AddDate(0, 0, 1)})
+	e.Remove("b")
+	if got := relatedSlugs(e.Related("a", 2)); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("after update: %q, want [c]", got)
+	}
+	if got := e.df["布局"]; got != 0 {
+		t.Errorf("document frequency of a replaced term is %d, want 0", got)
+	}
+}
+
+func relatedSlugs(posts []Post) []string {
+	slugs := make([]string, 0, len(posts))
+	for _, p := range posts {
+		slugs = append(slugs, p.Slug)
+	}
+	return slugs
+}
@@ -0,0 +1,109 @@
+package blog
+
+import (
+	"fmt"
+	"time"
+)
+
+// RendererVersion identifies the output of the Markdown renderer. Bump it when
+// a change to the renderer, its extensions or the shortcode templates changes
+// the stored HTML; posts and pages rendered by an older version are rendered
+// again at startup.
+const RendererVersion = 2
+
+// RenderingStore wraps a Store and renders Markdown once on Create and Update,
+// so readers get stored HTML instead of re-rendering on every request.
+type RenderingStore struct {
+	Store
+	render RenderFunc
+}
+
+func NewRenderingStore(store Store, render RenderFunc) *RenderingStore {
+	return &RenderingStore{Store: store, render: render}
+}
+
+func (s *RenderingStore) Create(post Post) error {
+	post.applyRendered(s.render(post), time.Now())
+	return s.Store.Create(post)
+}
+
+func (s *RenderingStore) Update(slug string, post Post) error {
+	post.applyRendered(s.render(post), time.Now())
+	return s.Store.Update(slug, post)
+}
+
+// RenderMissing renders posts that have never been rendered or were rendered
+// by another RendererVersion. It returns how many were rendered.
+func (s *RenderingStore) RenderMissing() (int, error) {
+	return s.rerender(func(p Post) bool { return p.RenderedAt.IsZero() || p.RendererVersion != RendererVersion })
+}
+
+// RerenderAll re-renders every post. Run it after changing the renderer or its extensions.
+func (s *RenderingStore) RerenderAll() (int, error) {
+	return s.rerender(func(Post) bool { return true })
+}
+
+func (s *RenderingStore) rerender(match func(Post) bool) (int, error) {
+	count := 0
+	for _, post := range s.Store.List() {
+		if !match(post) {
+			continue
+		}
+		if err := s.Store.SaveRendered(post.Slug, s.render(post)); err != nil {
+			return count, fmt.Errorf("render %s: %w", post.Slug, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// RenderingPageStore does for pages what RenderingStore does for posts: the
+// Markdown is rendered once on CreatePage and UpdatePage.
+type RenderingPageStore struct {
+	PageStore
+	render RenderFunc
+}
+
+func NewRenderingPageStore(store PageStore, render RenderFunc) *RenderingPageStore {
+	return &RenderingPageStore{PageStore: store, render: render}
+}
+
+func (s *RenderingPageStore) CreatePage(page Page) error {
+	page.applyRendered(s.renderPage(page), time.Now())
+	return s.PageStore.CreatePage(page)
+}
+
+func (s *RenderingPageStore) UpdatePage(path string, page Page) error {
+	page.applyRendered(s.renderPage(page), time.Now())
+	return s.PageStore.UpdatePage(path, page)
+}
+
+// RenderMissing renders pages that have never been rendered or were rendered
+// by another RendererVersion, and returns how many were rendered.
+func (s *RenderingPageStore) RenderMissing() (int, error) {
+	return s.rerender(func(p Page) bool { return p.RenderedAt.IsZero() || p.RendererVersion != RendererVersion })
+}
+
+// RerenderAll re-renders every page.
+func (s *RenderingPageStore) RerenderAll() (int, error) {
+	return s.rerender(func(Page) bool { return true })
+}
+
+func (s *RenderingPageStore) rerender(match func(Page) bool) (int, error) {
+	count := 0
+	for _, page := range s.PageStore.ListPages() {
+		if !match(page) {
+			continue
+		}
+		if err := s.PageStore.SavePageRendered(page.Path, s.renderPage(page)); err != nil {
+			return count, fmt.Errorf("render page %s: %w", page.Path, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// renderPage renders a page's Markdown like a post body.
+func (s *RenderingPageStore) renderPage(page Page) string {
+	return s.render(Post{Content: page.Content}).HTML
+}
@@ -0,0 +1,103 @@
+package blog
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderMissingOutdatedVersion(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "blog.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	renders := 0
+	rendering := NewRenderingStore(s, func(p Post) Rendered {
+		renders++
+		return Rendered{HTML: "<p>" + p.Content + "</p>"}
+	})
+	pages := NewRenderingPageStore(s, func(p Post) Rendered { return Rendered{HTML: p.Content} })
+	if err := rendering.Create(Post{Slug: "current", Title: "当前", Content: "a"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := rendering.Create(Post{Slug: "outdated", Title: "旧版", Content: "b"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.Create(Post{Slug: "never", Title: "未渲染", Content: "c"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := pages.CreatePage(Page{Path: "about", Title: "关于", Content: "d"}); err != nil {
+		t.Fatalf("CreatePage: %v", err)
+	}
+	// 模拟旧版本渲染器留下的行
+	if _, err := s.db.Exec("UPDATE posts SET renderer_version = ? WHERE slug = 'outdated'", RendererVersion-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("UPDATE pages SET renderer_version = ?", RendererVersion-1); err != nil {
+		t.Fatal(err)
+	}
+
+	renders = 0
+	n, err := rendering.RenderMissing()
+	if err != nil {
+		t.Fatalf("RenderMissing: %v", err)
+	}
+	if n != 2 || renders != 2 {
+		t.Errorf("rendered %d posts (%d calls), want 2", n, renders)
+	}
+	for _, slug := range []string{"current", "outdated", "never"} {
+		post, _ := s.GetBySlug(slug)
+		if post.RendererVersion != RendererVersion || post.RenderedAt.IsZero() {
+			t.Errorf("%s: renderer version %d, rendered at %v", slug, post.RendererVersion, post.RenderedAt)
+		}
+	}
+	if n, _ := rendering.RenderMissing(); n != 0 {
+		t.Errorf("second run rendered %d posts, want 0", n)
+	}
+
+	if n, err := pages.RenderMissing(); err != nil || n != 1 {
+		t.Errorf("RenderMissing pages = %d, %v; want 1", n, err)
+	}
+	if page, _ := s.GetPage("about"); page.RendererVersion != RendererVersion {
+		t.Errorf("page renderer version %d, want %d", page.RendererVersion, RendererVersion)
+	}
+}
+
+func TestRenderingPageStoreStoresHTML(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "blog.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	pages := NewRenderingPageStore(s, func(p Post) Rendered { return Rendered{HTML: "<p>" + p.Content + "</p>"} })
+	if err := pages.CreatePage(Page{Path: "about", Title: "关于", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePage: %v", err)
+	}
+	page, _ := s.GetPage("about")
+	if page.ContentHTML != "<p>v1</p>" || page.RenderedAt.IsZero() {
+		t.Errorf("after create: HTML %q, rendered at %v", page.ContentHTML, page.RenderedAt)
+	}
+
+	page.Content = "v2"
+	if err := pages.UpdatePage("about", page); err != nil {
+		t.Fatalf("UpdatePage: %v", err)
+	}
+	updated, _ := s.GetPage("about")
+	if updated.ContentHTML != "<p>v2</p>" {
+		t.Errorf("after update: HTML %q", updated.ContentHTML)
+	}
+
+	// 重新渲染只替换 HTML，不改变页面的更新时间
+	if err := s.SavePageRendered("about", "<p>v2, again</p>"); err != nil {
+		t.Fatalf("SavePageRendered: %v", err)
+	}
+	again, _ := s.GetPage("about")
+	if again.ContentHTML != "<p>v2, again</p>" || !again.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("after SavePageRendered: HTML %q, updated at %v, want %v", again.ContentHTML, again.UpdatedAt, updated.UpdatedAt)
+	}
+	if err := s.SavePageRendered("missing", "x"); err != ErrPageNotFound {
+		t.Errorf("SavePageRendered of a missing page: %v, want ErrPageNotFound", err)
+	}
+}
@@ -0,0 +1,143 @@
+package blog
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrSeriesNotFound = errors.New("series not found")
+var ErrDuplicateSeries = errors.New("series slug already exists")
+
+// Series groups posts that are meant to be read in order, such as a
+// multi-part tutorial. Membership lives on each post (Post.Series and
+// Post.SeriesOrder); the series itself only carries its name and description.
+type Series struct {
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SeriesStore manages series metadata.
+type SeriesStore interface {
+	ListSeries() []Series
+	GetSeries(slug string) (Series, bool)
+	CreateSeries(series Series) error
+	// UpdateSeries also moves member posts when the slug changes.
+	UpdateSeries(slug string, series Series) error
+	// DeleteSeries removes the series and detaches its posts.
+	DeleteSeries(slug string) error
+}
+
+const selectSeries = "SELECT slug, name, description, created_at, updated_at FROM series"
+
+func (s *SQLiteStore) ListSeries() []Series {
+	rows, err := s.db.Query(selectSeries + " ORDER BY name")
+	if err != nil {
+		log.Printf("sqlite: query series: %v", err)
+		return []Series{}
+	}
+	defer rows.Close()
+
+	var list []Series
+	for rows.Next() {
+		series, err := scanSeries(rows)
+		if err != nil {
+			log.Printf("sqlite: scan series: %v", err)
+			continue
+		}
+		list = append(list, series)
+	}
+	return list
+}
+
+func (s *SQLiteStore) GetSeries(slug string) (Series, bool) {
+	series, err := scanSeries(s.db.QueryRow(selectSeries+" WHERE slug = ?", slug))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("sqlite: get series %s: %v", slug, err)
+		}
+		return Series{}, false
+	}
+	return series, true
+}
+
+func (s *SQLiteStore) CreateSeries(series Series) error {
+	if series.Slug == "" {
+		return ErrInvalidSlug
+	}
+	if s.count("SELECT COUNT(*) FROM series WHERE slug = ?", series.Slug) > 0 {
+		return ErrDuplicateSeries
+	}
+	now := time.Now()
+	series.CreatedAt = now
+	series.UpdatedAt = now
+	_, err := s.db.Exec("INSERT INTO series (slug, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		series.Slug, series.Name, series.Description, series.CreatedAt, series.UpdatedAt)
+	return err
+}
+
+// UpdateSeries changes a series and, when the slug changes, moves its posts
+// in the same transaction. The posts keep their updated_at: their content
+// did not change.
+func (s *SQLiteStore) UpdateSeries(slug string, series Series) error {
+	if series.Slug == "" {
+		series.Slug = slug
+	}
+	if series.Slug != slug && s.count("SELECT COUNT(*) FROM series WHERE slug = ?", series.Slug) > 0 {
+		return ErrDuplicateSeries
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE series SET slug = ?, name = ?, description = ?, updated_at = ? WHERE slug = ?",
+		series.Slug, series.Name, series.Description, time.Now(), slug)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrSeriesNotFound
+	}
+	if series.Slug != slug {
+		if _, err := tx.Exec("UPDATE posts SET series_slug = ? WHERE series_slug = ?", series.Slug, slug); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteSeries removes a series and detaches its posts in one transaction.
+func (s *SQLiteStore) DeleteSeries(slug string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 文章保留，只解除与系列的关联
+	if _, err := tx.Exec("UPDATE posts SET series_slug = '', series_order = 0 WHERE series_slug = ?", slug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM series WHERE slug = ?", slug); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanSeries(row rowScanner) (Series, error) {
+	var series Series
+	var createdAt, updatedAt sql.NullTime
+	if err := row.Scan(&series.Slug, &series.Name, &series.Description, &createdAt, &updatedAt); err != nil {
+		return Series{}, err
+	}
+	series.CreatedAt = createdAt.Time
+	series.UpdatedAt = updatedAt.Time
+	return series, nil
+}
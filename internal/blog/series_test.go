@@ -0,0 +1,78 @@
+package blog
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSeriesRenameAndDelete(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "blog.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateSeries(Series{Slug: "old", Name: "旧系列"}); err != nil {
+		t.Fatalf("CreateSeries: %v", err)
+	}
+	for _, post := range []Post{
+		{Slug: "part-1", Title: "一", Series: "old", SeriesOrder: 1},
+		{Slug: "part-2", Title: "二", Series: "old", SeriesOrder: 2, IsDraft: true},
+		{Slug: "other", Title: "其它"},
+	} {
+		if err := s.Create(post); err != nil {
+			t.Fatalf("Create %s: %v", post.Slug, err)
+		}
+	}
+	before, _ := s.GetBySlug("part-1")
+
+	tests := []struct {
+		name  string
+		apply func() error
+		want  map[string]string
+		order map[string]int
+	}{
+		{
+			name:  "rename moves drafts too",
+			apply: func() error { return s.UpdateSeries("old", Series{Slug: "new", Name: "新系列"}) },
+			want:  map[string]string{"part-1": "new", "part-2": "new", "other": ""},
+			order: map[string]int{"part-1": 1, "part-2": 2},
+		},
+		{
+			name:  "delete detaches posts",
+			apply: func() error { return s.DeleteSeries("new") },
+			want:  map[string]string{"part-1": "", "part-2": "", "other": ""},
+			order: map[string]int{"part-1": 0, "part-2": 0},
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.apply(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for slug, series := range tt.want {
+			post, ok := s.GetBySlug(slug)
+			if !ok {
+				t.Fatalf("%s: post %s missing", tt.name, slug)
+			}
+			if post.Series != series {
+				t.Errorf("%s: %s is in series %q, want %q", tt.name, slug, post.Series, series)
+			}
+			if want, ok := tt.order[slug]; ok && post.SeriesOrder != want {
+				t.Errorf("%s: %s has order %d, want %d", tt.name, slug, post.SeriesOrder, want)
+			}
+		}
+	}
+
+	// 系列改名不算文章修改，不应改变文章的更新时间
+	after, _ := s.GetBySlug("part-1")
+	if !after.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("UpdatedAt changed from %s to %s", before.UpdatedAt.Format(time.RFC3339Nano), after.UpdatedAt.Format(time.RFC3339Nano))
+	}
+	if _, ok := s.GetSeries("new"); ok {
+		t.Error("series still exists after DeleteSeries")
+	}
+	if err := s.UpdateSeries("missing", Series{Name: "x"}); err != ErrSeriesNotFound {
+		t.Errorf("UpdateSeries of a missing series: %v, want ErrSeriesNotFound", err)
+	}
+}
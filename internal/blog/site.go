@@ -16,6 +16,14 @@ type SiteProfile struct {
 	Newsletter   string       `json:"newsletter"`
 	CurrentFocus []string     `json:"current_focus"`
 	SocialLinks  []SocialLink `json:"social_links"`
+	// Menus 按名称（header、footer）保存导航菜单，未配置时使用默认菜单
+	Menus map[string][]MenuItem `json:"menus,omitempty"`
+	// Theme 是 themes/ 下的主题目录名，为空时使用配置中的 THEME
+	Theme string `json:"theme,omitempty"`
+	// Locale 是界面的默认语言，访客浏览器的 Accept-Language 优先
+	Locale string `json:"locale,omitempty"`
+	// Robots 是 robots.txt 的规则，为空时使用默认规则
+	Robots string `json:"robots,omitempty"`
 }
 
 type SocialLink struct {
@@ -5,12 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 type SiteStore struct {
-	path string
-	mu   sync.RWMutex
-	data SiteProfile
+	path      string
+	mu        sync.RWMutex
+	data      SiteProfile
+	updatedAt time.Time
+	listeners []func(SiteProfile)
 }
 
 func NewSiteStore(path string) (*SiteStore, error) {
@@ -27,24 +30,52 @@ func (s *SiteStore) Get() SiteProfile {
 	return s.data
 }
 
-func (s *SiteStore) Update(profile SiteProfile) error {
+// UpdatedAt reports when the profile last changed, falling back to the file's mtime after a restart.
+func (s *SiteStore) UpdatedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updatedAt
+}
+
+// OnUpdate registers fn to be called after every successful Update.
+func (s *SiteStore) OnUpdate(fn func(SiteProfile)) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *SiteStore) Update(profile SiteProfile) error {
+	s.mu.Lock()
 	s.data = profile
-	return s.save()
+	if err := s.save(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.updatedAt = time.Now()
+	listeners := append([]func(SiteProfile){}, s.listeners...)
+	s.mu.Unlock()
+
+	// 回调在锁外执行，监听者可以安全地再次调用 Get
+	for _, fn := range listeners {
+		fn(profile)
+	}
+	return nil
 }
 
 func (s *SiteStore) load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, err := os.Stat(s.path); err != nil {
+	info, err := os.Stat(s.path)
+	if err != nil {
 		if os.IsNotExist(err) {
 			s.data = defaultProfile()
+			s.updatedAt = time.Now()
 			return s.save()
 		}
 		return err
 	}
+	s.updatedAt = info.ModTime()
 
 	data, err := os.ReadFile(s.path)
 	if err != nil {
@@ -4,9 +4,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
-	"sort"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -16,7 +17,22 @@ type SQLiteStore struct {
 	db *sql.DB
 }
 
+// NewSQLiteStore opens the database and applies any pending schema migrations.
 func NewSQLiteStore(dsn string) (*SQLiteStore, error) {
+	s, err := OpenSQLiteStore(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Migrate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSQLiteStore opens the database without touching the schema.
+// Used by tooling that needs to inspect migration status before upgrading.
+func OpenSQLiteStore(dsn string) (*SQLiteStore, error) {
 	// 确保数据库文件所在的目录存在
 	dir := filepath.Dir(dsn)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -45,41 +61,20 @@ func NewSQLiteStore(dsn string) (*SQLiteStore, error) {
 		return nil, fmt.Errorf("failed to set busy timeout: %w", err)
 	}
 
-	s := &SQLiteStore{db: db}
-	if err := s.init(); err != nil {
-		return nil, err
-	}
-	return s, nil
+	return &SQLiteStore{db: db}, nil
 }
 
-func (s *SQLiteStore) init() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS posts (
-		slug TEXT PRIMARY KEY,
-		title TEXT NOT NULL,
-		summary TEXT,
-		content TEXT,
-		category TEXT,
-		tags TEXT,
-		cover_image TEXT,
-		featured BOOLEAN,
-		is_draft BOOLEAN,
-		created_at DATETIME,
-		updated_at DATETIME
-	);
-	CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at DESC);
-	`
-	_, err := s.db.Exec(query)
-	return err
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
 }
 
 func (s *SQLiteStore) List() []Post {
 	// List usually implies all posts, ordered by created_at desc
-	return s.queryPosts("SELECT * FROM posts ORDER BY created_at DESC")
+	return s.queryPosts(selectPosts + " ORDER BY created_at DESC")
 }
 
 func (s *SQLiteStore) ListPublished() []Post {
-	return s.queryPosts("SELECT * FROM posts WHERE is_draft = 0 ORDER BY created_at DESC")
+	return s.queryPosts(selectPosts + " WHERE is_draft = 0 ORDER BY created_at DESC")
 }
 
 func (s *SQLiteStore) ListPaginated(page, pageSize int) ([]Post, int) {
@@ -88,8 +83,8 @@ func (s *SQLiteStore) ListPaginated(page, pageSize int) ([]Post, int) {
 	if offset < 0 {
 		offset = 0
 	}
-	query := fmt.Sprintf("SELECT * FROM posts ORDER BY created_at DESC LIMIT %d OFFSET %d", pageSize, offset)
-	return s.queryPosts(query), total
+	posts := s.queryPosts(selectPosts+" ORDER BY created_at DESC LIMIT ? OFFSET ?", pageSize, offset)
+	return posts, total
 }
 
 func (s *SQLiteStore) ListPublishedPaginated(page, pageSize int) ([]Post, int) {
@@ -98,77 +93,93 @@ func (s *SQLiteStore) ListPublishedPaginated(page, pageSize int) ([]Post, int) {
 	if offset < 0 {
 		offset = 0
 	}
-	query := fmt.Sprintf("SELECT * FROM posts WHERE is_draft = 0 ORDER BY created_at DESC LIMIT %d OFFSET %d", pageSize, offset)
-	return s.queryPosts(query), total
+	posts := s.queryPosts(selectPosts+" WHERE is_draft = 0 ORDER BY created_at DESC LIMIT ? OFFSET ?", pageSize, offset)
+	return posts, total
 }
 
 func (s *SQLiteStore) GetBySlug(slug string) (Post, bool) {
-	posts := s.queryPosts("SELECT * FROM posts WHERE slug = ?", slug)
+	posts := s.queryPosts(selectPosts+" WHERE slug = ?", slug)
 	if len(posts) == 0 {
 		return Post{}, false
 	}
 	return posts[0], true
 }
 
+// GetRelated ranks published posts by shared tags, then same category, then recency,
+// so posts without tags still get recent posts from their category.
 func (s *SQLiteStore) GetRelated(slug string, n int) []Post {
-	// 1. Get current post tags
-	current, ok := s.GetBySlug(slug)
-	if !ok || len(current.Tags) == 0 {
+	if n <= 0 || s.count("SELECT COUNT(*) FROM posts WHERE slug = ?", slug) == 0 {
 		return []Post{}
 	}
 
-	// 2. Ideally we use full text search or a junction table for tags, but for simple port:
-	// We will just fetch all published posts and do the scoring in memory (hybrid approach)
-	// or use LIKE queries. Given SQLite and small dataset, hybrid is fine.
-	// But let's try to be a bit smarter with SQL if possible.
-	// Actually, just fetching all published is fine for < 1000 posts.
-	// For "Modern & Intelligent", let's stick to the memory logic we just wrote,
-	// but fetch from DB.
+	query := selectPosts + `
+	WHERE is_draft = 0 AND slug != ?
+	ORDER BY
+		(SELECT COUNT(*) FROM post_tags pt
+			JOIN post_tags cur ON cur.tag = pt.tag AND cur.post_slug = ?
+			WHERE pt.post_slug = posts.slug) DESC,
+		(category != '' AND category = (SELECT category FROM posts WHERE slug = ?)) DESC,
+		created_at DESC
+	LIMIT ?`
+	return s.queryPosts(query, slug, slug, slug, n)
+}
 
-	all := s.ListPublished()
-	// Re-use the scoring logic
-	type scoredPost struct {
-		post  Post
-		score int
-	}
-	var candidates []scoredPost
-	currentTags := make(map[string]bool)
-	for _, t := range current.Tags {
-		currentTags[t] = true
-	}
+func (s *SQLiteStore) PrevPost(slug string) (Post, bool) {
+	return s.adjacentPost(slug, "<", "DESC")
+}
 
-	for _, p := range all {
-		if p.Slug == slug {
-			continue
-		}
-		score := 0
-		for _, t := range p.Tags {
-			if currentTags[t] {
-				score++
-			}
-		}
-		if score > 0 {
-			candidates = append(candidates, scoredPost{post: p, score: score})
-		}
+func (s *SQLiteStore) NextPost(slug string) (Post, bool) {
+	return s.adjacentPost(slug, ">", "ASC")
+}
+
+// adjacentPost 按 created_at 查找相邻的已发布文章，时间相同时用 slug 决定先后
+func (s *SQLiteStore) adjacentPost(slug, cmp, order string) (Post, bool) {
+	query := selectPosts + `
+	WHERE is_draft = 0 AND slug != ? AND (
+		created_at ` + cmp + ` (SELECT created_at FROM posts WHERE slug = ?)
+		OR (created_at = (SELECT created_at FROM posts WHERE slug = ?) AND slug ` + cmp + ` ?)
+	)
+	ORDER BY created_at ` + order + `, slug ` + order + `
+	LIMIT 1`
+	posts := s.queryPosts(query, slug, slug, slug, slug)
+	if len(posts) == 0 {
+		return Post{}, false
 	}
+	return posts[0], true
+}
 
-	// Sort
-	sort.Slice(candidates, func(i, j int) bool {
-		if candidates[i].score != candidates[j].score {
-			return candidates[i].score > candidates[j].score
-		}
-		return candidates[i].post.CreatedAt.After(candidates[j].post.CreatedAt)
-	})
+func (s *SQLiteStore) ListByTag(tag string) []Post {
+	query := selectPosts + `
+	WHERE is_draft = 0 AND slug IN (SELECT post_slug FROM post_tags WHERE tag = ?)
+	ORDER BY created_at DESC`
+	return s.queryPosts(query, tag)
+}
 
-	if len(candidates) > n {
-		candidates = candidates[:n]
-	}
+func (s *SQLiteStore) ListByCategory(category string) []Post {
+	return s.queryPosts(selectPosts+" WHERE is_draft = 0 AND category = ? ORDER BY created_at DESC", category)
+}
 
-	var result []Post
-	for _, c := range candidates {
-		result = append(result, c.post)
-	}
-	return result
+func (s *SQLiteStore) ListBySeries(series string) []Post {
+	return s.queryPosts(selectPosts+" WHERE is_draft = 0 AND series_slug = ? ORDER BY series_order, created_at", series)
+}
+
+func (s *SQLiteStore) ListTranslations(group string) []Post {
+	return s.queryPosts(selectPosts+" WHERE is_draft = 0 AND (slug = ? OR translation_of = ?) ORDER BY lang, created_at", group, group)
+}
+
+func (s *SQLiteStore) TagCounts() []TermCount {
+	return s.queryTermCounts(`
+	SELECT pt.tag, COUNT(*) FROM post_tags pt
+	JOIN posts ON posts.slug = pt.post_slug
+	WHERE posts.is_draft = 0
+	GROUP BY pt.tag ORDER BY pt.tag`)
+}
+
+func (s *SQLiteStore) CategoryCounts() []TermCount {
+	return s.queryTermCounts(`
+	SELECT category, COUNT(*) FROM posts
+	WHERE is_draft = 0 AND category IS NOT NULL AND category != ''
+	GROUP BY category ORDER BY category`)
 }
 
 func (s *SQLiteStore) Create(post Post) error {
@@ -178,81 +189,249 @@ func (s *SQLiteStore) Create(post Post) error {
 	}
 	post.UpdatedAt = now
 
-	tagsJSON, _ := json.Marshal(post.Tags)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
 	query := `
-	INSERT INTO posts (slug, title, summary, content, category, tags, cover_image, featured, is_draft, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO posts (slug, title, summary, content, category, cover_image, featured, is_draft, show_toc, series_slug, series_order, lang, translation_of, created_at, updated_at,
+		content_html, content_text, word_count, outline, first_image, rendered_at, renderer_version)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(query, post.Slug, post.Title, post.Summary, post.Content, post.Category, string(tagsJSON), post.CoverImage, post.Featured, post.IsDraft, post.CreatedAt, post.UpdatedAt)
-	return err
+	_, err = tx.Exec(query, post.Slug, post.Title, post.Summary, post.Content, post.Category, post.CoverImage, post.Featured, post.IsDraft, post.ShowTOC, post.Series, post.SeriesOrder, post.Lang, post.TranslationOf, post.CreatedAt, post.UpdatedAt,
+		post.ContentHTML, post.ContentText, post.WordCount, outlineJSON(post.Outline), post.FirstImage, nullTime(post.RenderedAt), post.RendererVersion)
+	if err != nil {
+		return err
+	}
+	if err := replaceTags(tx, post.Slug, post.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *SQLiteStore) Update(slug string, post Post) error {
-	// Check if exists
-	// We need original ID or just update by slug.
-	// If slug changes, we need to handle that.
-	// The interface implies "Update the post identified by 'slug' with new data 'post'".
 	// If 'post.Slug' is different, we update the slug too.
-
 	post.UpdatedAt = time.Now()
-	tagsJSON, _ := json.Marshal(post.Tags)
-
 	if post.Slug == "" {
 		post.Slug = slug
 	}
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 	UPDATE posts SET 
-		slug = ?, title = ?, summary = ?, content = ?, category = ?, tags = ?, 
-		cover_image = ?, featured = ?, is_draft = ?, updated_at = ?
+		slug = ?, title = ?, summary = ?, content = ?, category = ?, 
+		cover_image = ?, featured = ?, is_draft = ?, show_toc = ?, series_slug = ?, series_order = ?, lang = ?, translation_of = ?, updated_at = ?,
+		content_html = ?, content_text = ?, word_count = ?, outline = ?, first_image = ?, rendered_at = ?, renderer_version = ?
 	WHERE slug = ?
 	`
-	_, err := s.db.Exec(query, post.Slug, post.Title, post.Summary, post.Content, post.Category, string(tagsJSON), post.CoverImage, post.Featured, post.IsDraft, post.UpdatedAt, slug)
-	return err
+	res, err := tx.Exec(query, post.Slug, post.Title, post.Summary, post.Content, post.Category, post.CoverImage, post.Featured, post.IsDraft, post.ShowTOC, post.Series, post.SeriesOrder, post.Lang, post.TranslationOf, post.UpdatedAt,
+		post.ContentHTML, post.ContentText, post.WordCount, outlineJSON(post.Outline), post.FirstImage, nullTime(post.RenderedAt), post.RendererVersion, slug)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	// 外键级联只在开启 foreign_keys 的连接上生效，这里显式清理旧 slug 的标签
+	if post.Slug != slug {
+		if _, err := tx.Exec("DELETE FROM post_tags WHERE post_slug = ?", slug); err != nil {
+			return err
+		}
+		// 原文改了 slug，译文跟着指向新 slug
+		if _, err := tx.Exec("UPDATE posts SET translation_of = ? WHERE translation_of = ?", post.Slug, slug); err != nil {
+			return err
+		}
+	}
+	if err := replaceTags(tx, post.Slug, post.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *SQLiteStore) Delete(slug string) error {
-	_, err := s.db.Exec("DELETE FROM posts WHERE slug = ?", slug)
-	return err
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM post_tags WHERE post_slug = ?", slug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM posts WHERE slug = ?", slug); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SaveRendered(slug string, rendered Rendered) error {
+	query := `
+	UPDATE posts SET
+		content_html = ?, content_text = ?, word_count = ?, outline = ?, first_image = ?, rendered_at = ?, renderer_version = ?
+	WHERE slug = ?
+	`
+	res, err := s.db.Exec(query, rendered.HTML, rendered.Text, rendered.WordCount, outlineJSON(rendered.Outline), rendered.FirstImage, time.Now(), RendererVersion, slug)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 // Helpers
 
+// postColumns 是读取文章时唯一的列清单，必须与 scanPost 的顺序一一对应。
+// 标签从 post_tags 聚合为 JSON 数组，保持写入时的顺序。
+const postColumns = `slug, title, summary, content, category, cover_image, featured, is_draft, show_toc, series_slug, series_order, lang, translation_of, created_at, updated_at,
+	content_html, content_text, word_count, outline, first_image, rendered_at, renderer_version,
+	(SELECT json_group_array(tag ORDER BY position) FROM post_tags WHERE post_tags.post_slug = posts.slug) AS tags`
+
+const selectPosts = "SELECT " + postColumns + " FROM posts"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row rowScanner) (Post, error) {
+	var p Post
+	var summary, content, category, coverImage, tagsRaw sql.NullString
+	var featured, isDraft, showTOC sql.NullBool
+	var createdAt, updatedAt, renderedAt sql.NullTime
+	var contentHTML, contentText, outlineRaw, firstImage sql.NullString
+	var seriesSlug, lang, translationOf sql.NullString
+	var wordCount, seriesOrder, rendererVersion sql.NullInt64
+
+	err := row.Scan(
+		&p.Slug, &p.Title, &summary, &content, &category,
+		&coverImage, &featured, &isDraft, &showTOC, &seriesSlug, &seriesOrder, &lang, &translationOf, &createdAt, &updatedAt,
+		&contentHTML, &contentText, &wordCount, &outlineRaw, &firstImage, &renderedAt, &rendererVersion,
+		&tagsRaw,
+	)
+	if err != nil {
+		return Post{}, err
+	}
+	p.Summary = summary.String
+	p.Content = content.String
+	p.Category = category.String
+	p.CoverImage = coverImage.String
+	p.Featured = featured.Bool
+	p.IsDraft = isDraft.Bool
+	p.ShowTOC = showTOC.Bool
+	p.Series = seriesSlug.String
+	p.SeriesOrder = int(seriesOrder.Int64)
+	p.Lang = lang.String
+	p.TranslationOf = translationOf.String
+	p.CreatedAt = createdAt.Time
+	p.UpdatedAt = updatedAt.Time
+	p.ContentHTML = contentHTML.String
+	p.ContentText = contentText.String
+	p.WordCount = int(wordCount.Int64)
+	p.FirstImage = firstImage.String
+	p.RenderedAt = renderedAt.Time
+	p.RendererVersion = int(rendererVersion.Int64)
+	if outlineRaw.Valid && outlineRaw.String != "" {
+		if err := json.Unmarshal([]byte(outlineRaw.String), &p.Outline); err != nil {
+			return Post{}, fmt.Errorf("decode outline of %q: %w", p.Slug, err)
+		}
+	}
+	if tagsRaw.Valid && tagsRaw.String != "" {
+		if err := json.Unmarshal([]byte(tagsRaw.String), &p.Tags); err != nil {
+			return Post{}, fmt.Errorf("decode tags of %q: %w", p.Slug, err)
+		}
+	}
+	if len(p.Tags) == 0 {
+		p.Tags = nil
+	}
+	return p, nil
+}
+
 func (s *SQLiteStore) queryPosts(query string, args ...any) []Post {
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
+		log.Printf("sqlite: query posts: %v", err)
 		return []Post{}
 	}
 	defer rows.Close()
 
 	var posts []Post
 	for rows.Next() {
-		var p Post
-		var tagsRaw string
-		var featured, isDraft bool // SQLite stores boolean as 0/1, driver handles it usually?
-		// modernc.org/sqlite handles bool as int64 usually. Let's be safe.
-		// actually modernc sqlite driver maps BOOL to bool automatically if defined in table?
-		// No, SQLite types are dynamic.
-		// Let's scan into bool, standard `database/sql` converts 1/0 to bool.
-
-		err := rows.Scan(
-			&p.Slug, &p.Title, &p.Summary, &p.Content, &p.Category, &tagsRaw,
-			&p.CoverImage, &featured, &isDraft, &p.CreatedAt, &p.UpdatedAt,
-		)
+		p, err := scanPost(rows)
 		if err != nil {
+			log.Printf("sqlite: scan post: %v", err)
 			continue
 		}
-		p.Featured = featured
-		p.IsDraft = isDraft
-		_ = json.Unmarshal([]byte(tagsRaw), &p.Tags)
 		posts = append(posts, p)
 	}
+	if err := rows.Err(); err != nil {
+		log.Printf("sqlite: iterate posts: %v", err)
+	}
 	return posts
 }
 
-func (s *SQLiteStore) count(query string) int {
+func (s *SQLiteStore) queryTermCounts(query string, args ...any) []TermCount {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("sqlite: query terms: %v", err)
+		return []TermCount{}
+	}
+	defer rows.Close()
+
+	var terms []TermCount
+	for rows.Next() {
+		var t TermCount
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			log.Printf("sqlite: scan term: %v", err)
+			continue
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+func (s *SQLiteStore) count(query string, args ...any) int {
 	var n int
-	_ = s.db.QueryRow(query).Scan(&n)
+	_ = s.db.QueryRow(query, args...).Scan(&n)
 	return n
 }
+
+func outlineJSON(outline []Heading) string {
+	if len(outline) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(outline)
+	return string(data)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func replaceTags(tx *sql.Tx, slug string, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM post_tags WHERE post_slug = ?", slug); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	position := 0
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if _, err := tx.Exec("INSERT INTO post_tags (post_slug, tag, position) VALUES (?, ?, ?)", slug, tag, position); err != nil {
+			return err
+		}
+		position++
+	}
+	return nil
+}
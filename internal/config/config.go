@@ -3,6 +3,7 @@ package config
 import (
 	"net"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +15,11 @@ type Config struct {
 	SiteBaseURL  string
 	AdminBaseURL string
 	DataDir      string
+	// Theme is the default theme name; the site settings can override it.
+	Theme string
+	// Dev reads templates and static files from the source tree instead of
+	// the copies embedded in the binary, and reloads them on change.
+	Dev bool
 }
 
 func Load() *Config {
@@ -40,6 +46,8 @@ func Load() *Config {
 		SiteBaseURL:  siteBaseURL,
 		AdminBaseURL: adminBaseURL,
 		DataDir:      getEnv("DATA_DIR", "data"),
+		Theme:        strings.TrimSpace(getEnv("THEME", "")),
+		Dev:          getEnvBool("DEV_MODE", false),
 	}
 }
 
@@ -74,6 +82,15 @@ func baseURLFromAddr(addr string) string {
 	return "http://" + host
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
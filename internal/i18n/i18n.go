@@ -0,0 +1,181 @@
+// Package i18n holds the message catalogs of the public UI and picks the
+// locale of a request.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// DefaultLocale is used when neither the site settings nor the visitor pick a
+// supported locale, and for messages missing from another catalog.
+const DefaultLocale = "zh-CN"
+
+// Locale is a UI language and its messages. Messages are fmt format strings.
+type Locale struct {
+	Code     string            `json:"code"`
+	Name     string            `json:"name"`
+	Messages map[string]string `json:"messages"`
+}
+
+var locales = loadLocales()
+
+func loadLocales() map[string]Locale {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	loaded := map[string]Locale{}
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var locale Locale
+		if err := json.Unmarshal(data, &locale); err != nil {
+			panic(fmt.Sprintf("i18n: parse %s: %v", file.Name(), err))
+		}
+		// 文件名就是语言代码，例如 zh-CN.json
+		locale.Code = strings.TrimSuffix(file.Name(), ".json")
+		loaded[locale.Code] = locale
+	}
+	if _, ok := loaded[DefaultLocale]; !ok {
+		panic("i18n: missing catalog for " + DefaultLocale)
+	}
+	return loaded
+}
+
+// Locales lists the supported UI locales, the default first.
+func Locales() []Locale {
+	list := make([]Locale, 0, len(locales))
+	for _, locale := range locales {
+		list = append(list, locale)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i].Code == DefaultLocale) != (list[j].Code == DefaultLocale) {
+			return list[i].Code == DefaultLocale
+		}
+		return list[i].Code < list[j].Code
+	})
+	return list
+}
+
+// T translates key into locale, formatting args into the message. Messages
+// missing from locale come from the default catalog; unknown keys are
+// returned as is.
+func T(locale, key string, args ...any) string {
+	msg, ok := locales[locale].Messages[key]
+	if !ok {
+		msg, ok = locales[DefaultLocale].Messages[key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Normalize maps a language tag to a supported locale code: an exact match
+// first (case-insensitive), then the first locale with the same primary
+// language, so "zh-Hans" and "zh" give "zh-CN" and "en-US" gives "en".
+// It returns "" when no locale fits.
+func Normalize(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return ""
+	}
+	for code := range locales {
+		if strings.EqualFold(code, tag) {
+			return code
+		}
+	}
+	primary := primaryLanguage(tag)
+	for _, locale := range Locales() {
+		if primaryLanguage(locale.Code) == primary {
+			return locale.Code
+		}
+	}
+	return ""
+}
+
+// Match picks the supported locale that best fits an Accept-Language header.
+func Match(acceptLanguage string) (string, bool) {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		if code := Normalize(c.tag); code != "" {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ValidTag reports whether tag looks like a BCP 47 language tag such as
+// "en", "zh-CN" or "zh-Hant-TW".
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// languageNames are the names of common content languages in themselves.
+var languageNames = map[string]string{
+	"zh":    "中文",
+	"zh-cn": "简体中文",
+	"zh-tw": "繁體中文",
+	"zh-hk": "繁體中文（香港）",
+	"en":    "English",
+	"ja":    "日本語",
+	"ko":    "한국어",
+	"fr":    "Français",
+	"de":    "Deutsch",
+	"es":    "Español",
+	"ru":    "Русский",
+}
+
+// LanguageName returns the name of a content language in that language,
+// falling back to the tag itself.
+func LanguageName(tag string) string {
+	if name, ok := languageNames[strings.ToLower(tag)]; ok {
+		return name
+	}
+	if name, ok := languageNames[primaryLanguage(tag)]; ok {
+		return name
+	}
+	return tag
+}
+
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	return primary
+}
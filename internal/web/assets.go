@@ -0,0 +1,261 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"myblog"
+)
+
+// assetsFromDisk switches the default theme from the copy embedded in the
+// binary to the source tree, so edits show up without rebuilding. NewServer
+// sets it in dev mode.
+var assetsFromDisk atomic.Bool
+
+// embeddedAssets holds the default theme's directories inside the binary,
+// resolved once at startup.
+var embeddedAssets = map[string]fs.FS{}
+
+func init() {
+	for _, dir := range []string{defaultTemplateDir, defaultStaticDir} {
+		sub, err := fs.Sub(myblog.Assets, dir)
+		if err != nil {
+			// 目录名是常量，出错说明 embed 声明与目录不一致，启动时就应失败
+			panic(err)
+		}
+		embeddedAssets[dir] = sub
+	}
+}
+
+// defaultAssets returns a directory of the default theme, e.g. "static" or
+// "internal/web/templates". In dev mode the directory is read from disk
+// relative to the working directory, so the server must be started from the
+// repository root.
+func defaultAssets(dir string) fs.FS {
+	if assetsFromDisk.Load() {
+		return os.DirFS(dir)
+	}
+	return embeddedAssets[dir]
+}
+
+// layeredFS looks a name up in each layer in turn, so earlier layers override
+// later ones file by file. Directory listings are merged.
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	var firstErr error
+	for _, layer := range l {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil || !errors.Is(err, fs.ErrNotExist) {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return nil, firstErr
+}
+
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	found := false
+	for _, layer := range l {
+		list, err := fs.ReadDir(layer, name)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, entry := range list {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// 指纹取内容 SHA-256 的前 10 位十六进制，插在扩展名之前：css/app.0123456789.css
+const fingerprintLen = 10
+
+var fingerprintPattern = regexp.MustCompile(`^(.*)\.([0-9a-f]{10})(\.[^./]+)?$`)
+
+// fingerprintName inserts a content hash into a static file name.
+func fingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// splitFingerprint is the inverse of fingerprintName.
+func splitFingerprint(name string) (original, hash string, ok bool) {
+	m := fingerprintPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1] + m[3], m[2], true
+}
+
+// assetHashes caches static file hashes per theme and path. In dev mode the
+// files can change at any time, so nothing is cached.
+type assetHashes struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func (s *Server) assetHash(name string) (string, error) {
+	key := s.ActiveTheme().Manifest.Name + "/" + name
+	if !s.Config.Dev {
+		s.hashes.mu.Lock()
+		hash, ok := s.hashes.hashes[key]
+		s.hashes.mu.Unlock()
+		if ok {
+			return hash, nil
+		}
+	}
+
+	f, err := s.staticFS().Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))[:fingerprintLen]
+
+	if !s.Config.Dev {
+		s.hashes.mu.Lock()
+		if s.hashes.hashes == nil {
+			s.hashes.hashes = map[string]string{}
+		}
+		s.hashes.hashes[key] = hash
+		s.hashes.mu.Unlock()
+	}
+	return hash, nil
+}
+
+// fingerprintURL rewrites /static/ paths to their fingerprinted form; other
+// URLs, and static files that do not exist, are returned unchanged.
+func (s *Server) fingerprintURL(raw string) string {
+	name, ok := strings.CutPrefix(raw, "/static/")
+	if !ok || strings.ContainsAny(name, "?#") {
+		return raw
+	}
+	hash, err := s.assetHash(name)
+	if err != nil {
+		return raw
+	}
+	return "/static/" + fingerprintName(name, hash)
+}
+
+// staticHandler serves /static/ from the active theme. Fingerprinted URLs
+// whose hash matches the current file are cached as immutable; a stale
+// fingerprint still gets the current file, just without long-term caching.
+func (s *Server) staticHandler() http.Handler {
+	return http.StripPrefix("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fsys := s.staticFS()
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if _, err := fs.Stat(fsys, name); err != nil {
+			if original, hash, ok := splitFingerprint(name); ok {
+				if current, err := s.assetHash(original); err == nil && current == hash {
+					w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+				} else {
+					w.Header().Set("Cache-Control", "no-cache")
+				}
+				r = r.Clone(r.Context())
+				r.URL.Path = "/" + original
+			}
+		}
+		http.FileServer(http.FS(fsys)).ServeHTTP(w, r)
+	}))
+}
+
+// ExportStatic writes the active theme's static files to dir, each under its
+// plain name and its fingerprinted name. Files already in dir with the same
+// content are left alone; it returns how many files were written and how many
+// were unchanged. exported, when not nil, is called with the slash-separated
+// name and contents of every file in the export, written or not.
+func (s *Server) ExportStatic(dir string, exported func(name string, data []byte)) (written, unchanged int, err error) {
+	fsys := s.staticFS()
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		hash, err := s.assetHash(name)
+		if err != nil {
+			return err
+		}
+		for _, out := range []string{name, fingerprintName(name, hash)} {
+			if exported != nil {
+				exported(out, data)
+			}
+			target := filepath.Join(dir, filepath.FromSlash(out))
+			if existing, err := os.ReadFile(target); err == nil && bytes.Equal(existing, data) {
+				unchanged++
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, data, 0644); err != nil {
+				return err
+			}
+			written++
+		}
+		return nil
+	})
+	return written, unchanged, err
+}
+
+// ThemeSignature hashes the names and contents of the active theme's
+// templates and static files, so callers can tell when rendered pages go
+// stale.
+func (s *Server) ThemeSignature() (string, error) {
+	h := sha256.New()
+	h.Write([]byte(s.ActiveTheme().Manifest.Name))
+	for _, fsys := range []fs.FS{s.templateFS(), s.staticFS()} {
+		err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			h.Write([]byte(name))
+			h.Write([]byte{0})
+			h.Write(data)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"myblog/internal/blog"
+)
+
+// linkGraph records which posts link to which, built from the internal
+// /posts/ links in each post's rendered HTML.
+type linkGraph struct {
+	mu   sync.RWMutex
+	base string
+	out  map[string]map[string]bool
+	in   map[string]map[string]bool
+}
+
+func newLinkGraph(baseURL string) *linkGraph {
+	return &linkGraph{
+		base: normalizeBaseURL(baseURL),
+		out:  map[string]map[string]bool{},
+		in:   map[string]map[string]bool{},
+	}
+}
+
+// rebuild replaces the graph with the links of the given posts.
+func (g *linkGraph) rebuild(posts []blog.Post) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.out = map[string]map[string]bool{}
+	g.in = map[string]map[string]bool{}
+	for _, p := range posts {
+		g.setLocked(p.Slug, g.targets(p))
+	}
+}
+
+// update refreshes the outgoing links of one post.
+func (g *linkGraph) update(post blog.Post) {
+	targets := g.targets(post)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.setLocked(post.Slug, targets)
+}
+
+func (g *linkGraph) remove(slug string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.setLocked(slug, nil)
+}
+
+func (g *linkGraph) setLocked(slug string, targets map[string]bool) {
+	for target := range g.out[slug] {
+		delete(g.in[target], slug)
+		if len(g.in[target]) == 0 {
+			delete(g.in, target)
+		}
+	}
+	delete(g.out, slug)
+	if len(targets) == 0 {
+		return
+	}
+	g.out[slug] = targets
+	for target := range targets {
+		if g.in[target] == nil {
+			g.in[target] = map[string]bool{}
+		}
+		g.in[target][slug] = true
+	}
+}
+
+// inbound returns the slugs of posts linking to slug.
+func (g *linkGraph) inbound(slug string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	sources := make([]string, 0, len(g.in[slug]))
+	for source := range g.in[slug] {
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// targets extracts the slugs of other posts linked from a post; self links
+// such as footnote or heading anchors are ignored. Hrefs are decoded first:
+// Markdown percent-encodes slugs with CJK characters or spaces.
+func (g *linkGraph) targets(post blog.Post) map[string]bool {
+	targets := map[string]bool{}
+	for _, link := range extractLinks(post.ContentHTML) {
+		raw := strings.TrimSpace(link.URL)
+		if g.base != "" && strings.HasPrefix(raw, g.base+"/") {
+			raw = strings.TrimPrefix(raw, g.base)
+		}
+		if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		rest, ok := strings.CutPrefix(u.Path, "/posts/")
+		if !ok {
+			continue
+		}
+		slug := strings.Trim(rest, "/")
+		if slug != "" && slug != post.Slug {
+			targets[slug] = true
+		}
+	}
+	return targets
+}
+
+// linkGraphStore keeps the link graph in sync with every successful write.
+// It wraps the rendering store, so GetBySlug already returns fresh HTML.
+type linkGraphStore struct {
+	blog.Store
+	graph *linkGraph
+}
+
+func (s *linkGraphStore) Create(post blog.Post) error {
+	if err := s.Store.Create(post); err != nil {
+		return err
+	}
+	s.refresh(post.Slug)
+	return nil
+}
+
+func (s *linkGraphStore) Update(slug string, post blog.Post) error {
+	if err := s.Store.Update(slug, post); err != nil {
+		return err
+	}
+	newSlug := post.Slug
+	if newSlug == "" {
+		newSlug = slug
+	}
+	if newSlug != slug {
+		s.graph.remove(slug)
+	}
+	s.refresh(newSlug)
+	return nil
+}
+
+func (s *linkGraphStore) Delete(slug string) error {
+	if err := s.Store.Delete(slug); err != nil {
+		return err
+	}
+	s.graph.remove(slug)
+	return nil
+}
+
+func (s *linkGraphStore) SaveRendered(slug string, rendered blog.Rendered) error {
+	if err := s.Store.SaveRendered(slug, rendered); err != nil {
+		return err
+	}
+	s.refresh(slug)
+	return nil
+}
+
+func (s *linkGraphStore) refresh(slug string) {
+	if post, ok := s.Store.GetBySlug(slug); ok {
+		s.graph.update(post)
+	}
+}
+
+// Backlinks returns the published posts that link to slug, newest first.
+func (s *Server) Backlinks(slug string) []blog.Post {
+	var posts []blog.Post
+	for _, source := range s.graph.inbound(slug) {
+		if post, ok := s.Store.GetBySlug(source); ok && !post.IsDraft {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].CreatedAt.After(posts[j].CreatedAt)
+	})
+	return posts
+}
+
+// OrphanPosts returns published posts that no other published post links to.
+func (s *Server) OrphanPosts() []blog.Post {
+	var orphans []blog.Post
+	for _, post := range s.Store.ListPublished() {
+		if len(s.Backlinks(post.Slug)) == 0 {
+			orphans = append(orphans, post)
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,294 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"myblog/internal/blog"
+)
+
+// renderCacheMaxEntries 限制缓存条目数量，防止任意查询参数把内存撑满
+const renderCacheMaxEntries = 512
+
+// renderCache stores fully rendered public pages keyed by request URI.
+// Every Store or SiteStore write bumps the content version and drops all entries.
+type renderCache struct {
+	mu           sync.RWMutex
+	version      uint64
+	lastModified time.Time
+	entries      map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	header       http.Header
+	body         []byte
+	etag         string
+	lastModified time.Time
+
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+func newRenderCache(lastModified time.Time) *renderCache {
+	return &renderCache{
+		lastModified: lastModified.UTC().Truncate(time.Second),
+		entries:      map[string]*cacheEntry{},
+	}
+}
+
+func (c *renderCache) get(key string) (*cacheEntry, uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[key]
+	return entry, c.version, ok
+}
+
+// put 只在渲染期间内容版本未变化时写入，避免把过期页面放回缓存
+func (c *renderCache) put(key string, version uint64, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version != c.version {
+		return
+	}
+	if len(c.entries) >= renderCacheMaxEntries {
+		c.entries = map[string]*cacheEntry{}
+	}
+	c.entries[key] = entry
+}
+
+func (c *renderCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	c.lastModified = time.Now().UTC().Truncate(time.Second)
+	c.entries = map[string]*cacheEntry{}
+}
+
+// LastModified is the time of the most recent content write.
+func (c *renderCache) LastModified() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastModified
+}
+
+// cached serves a public page from the render cache, rendering it on a miss,
+// and answers conditional requests with 304.
+func (s *Server) cached(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// HTMX 局部请求返回片段，不与整页共用缓存；开发模式下模板随时会变，不缓存
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("HX-Request") != "" || s.Config.Dev {
+			next(w, r)
+			return
+		}
+
+		// 界面语言随 Accept-Language 变化，按语言分别缓存
+		key := s.locale(r) + " " + r.URL.RequestURI()
+		w.Header().Add("Vary", "Accept-Language")
+		entry, version, ok := s.cache.get(key)
+		if !ok {
+			buf := newBufferedResponse()
+			next(buf, r)
+			if buf.status != http.StatusOK {
+				buf.writeTo(w)
+				return
+			}
+			// 压缩后的响应不会再被 net/http 嗅探类型，缓存前补上
+			if buf.header.Get("Content-Type") == "" {
+				buf.header.Set("Content-Type", http.DetectContentType(buf.body.Bytes()))
+			}
+			entry = newCacheEntry(buf, s.cache.LastModified())
+			s.cache.put(key, version, entry)
+		}
+		entry.serve(w, r)
+	}
+}
+
+// newCacheEntry stamps the entry with the Last-Modified the handler set, or
+// else with the content version's time: most pages show many posts, the
+// navigation and the site settings, so only handlers that know everything a
+// page depends on (such as PostDetail) give a more precise time.
+func newCacheEntry(buf *bufferedResponse, lastModified time.Time) *cacheEntry {
+	sum := sha256.Sum256(buf.body.Bytes())
+	entry := &cacheEntry{
+		header:       buf.header.Clone(),
+		body:         buf.body.Bytes(),
+		etag:         `"` + hex.EncodeToString(sum[:12]) + `"`,
+		lastModified: lastModified,
+		encoded:      map[string][]byte{},
+	}
+	if raw := entry.header.Get("Last-Modified"); raw != "" {
+		if t, err := http.ParseTime(raw); err == nil {
+			entry.lastModified = t
+		}
+	}
+	entry.header.Del("Last-Modified")
+	entry.header.Del("Content-Length")
+	return entry
+}
+
+func (e *cacheEntry) serve(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	for k, v := range e.header {
+		h[k] = v
+	}
+
+	body := e.body
+	etag := e.etag
+	encoding := ""
+	if len(e.body) >= compressMinSize {
+		encoding = negotiateEncoding(r)
+	}
+	if encoding != "" {
+		encoded, err := e.encode(encoding)
+		if err == nil {
+			body = encoded
+			h.Set("Content-Encoding", encoding)
+			// 不同编码是不同的表示，需要不同的强校验值
+			etag = strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+		}
+	}
+	h.Set("ETag", etag)
+	h.Add("Vary", "Accept-Encoding")
+	if !e.lastModified.IsZero() {
+		h.Set("Last-Modified", e.lastModified.UTC().Format(http.TimeFormat))
+	}
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", "public, no-cache")
+	}
+
+	// 已经压缩过的响应不再经过压缩中间件
+	if cw, ok := w.(*compressWriter); ok {
+		cw.bypass = true
+	}
+
+	if notModified(r, etag, e.lastModified) {
+		h.Del("Content-Type")
+		h.Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+func (e *cacheEntry) encode(encoding string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if data, ok := e.encoded[encoding]; ok {
+		return data, nil
+	}
+	data, err := compressBytes(encoding, e.body)
+	if err != nil {
+		return nil, err
+	}
+	e.encoded[encoding] = data
+	return data, nil
+}
+
+// notModified implements RFC 9110 precedence: If-None-Match wins over If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
+
+// bufferedResponse captures a handler's output so it can be cached.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range b.header {
+		h[k] = v
+	}
+	status := b.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(b.body.Bytes())
+}
+
+// invalidatingStore drops the render cache after every successful write.
+type invalidatingStore struct {
+	blog.Store
+	cache *renderCache
+}
+
+func (s *invalidatingStore) Create(post blog.Post) error {
+	if err := s.Store.Create(post); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingStore) Update(slug string, post blog.Post) error {
+	if err := s.Store.Update(slug, post); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingStore) Delete(slug string) error {
+	if err := s.Store.Delete(slug); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingStore) SaveRendered(slug string, rendered blog.Rendered) error {
+	if err := s.Store.SaveRendered(slug, rendered); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myblog/internal/config"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	etag := `"abc"`
+	tests := []struct {
+		name         string
+		ifNoneMatch  string
+		ifModified   string
+		lastModified time.Time
+		want         bool
+	}{
+		{name: "no conditions", lastModified: modified},
+		{name: "matching etag", ifNoneMatch: `"abc"`, want: true},
+		{name: "weak etag", ifNoneMatch: `W/"abc"`, want: true},
+		{name: "etag in list", ifNoneMatch: `"x", "abc"`, want: true},
+		{name: "wildcard", ifNoneMatch: "*", want: true},
+		{name: "other etag", ifNoneMatch: `"x"`},
+		{name: "etag wins over date", ifNoneMatch: `"x"`, ifModified: modified.Format(http.TimeFormat), lastModified: modified},
+		{name: "same date", ifModified: modified.Format(http.TimeFormat), lastModified: modified, want: true},
+		{name: "later date", ifModified: modified.Add(time.Hour).Format(http.TimeFormat), lastModified: modified, want: true},
+		{name: "earlier date", ifModified: modified.Add(-time.Second).Format(http.TimeFormat), lastModified: modified},
+		{name: "sub-second modification", ifModified: modified.Format(http.TimeFormat), lastModified: modified.Add(500 * time.Millisecond), want: true},
+		{name: "unknown modification time", ifModified: modified.Format(http.TimeFormat)},
+		{name: "invalid date", ifModified: "yesterday", lastModified: modified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModified != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModified)
+			}
+			if got := notModified(r, etag, tt.lastModified); got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// cacheTestServer serves body through the render cache and counts renders.
+func cacheTestServer(modified time.Time, status int, body string) (*Server, http.HandlerFunc, *int) {
+	s := &Server{Config: &config.Config{}, cache: newRenderCache(modified)}
+	renders := 0
+	handler := s.cached(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+	return s, handler, &renders
+}
+
+func cacheRequest(handler http.HandlerFunc, method string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/posts", nil)
+	// 指定语言，避免按站点设置回退
+	r.Header.Set("Accept-Language", "en")
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestCachedConditionalRequests(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	_, handler, renders := cacheTestServer(modified, http.StatusOK, "<!doctype html><p>hello</p>")
+
+	first := cacheRequest(handler, http.MethodGet, nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first response: %d, ETag %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Last-Modified"); got != modified.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %q, want the content version time", got)
+	}
+	if got := first.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+		body   string
+	}{
+		{"plain", http.MethodGet, nil, http.StatusOK, "<!doctype html><p>hello</p>"},
+		{"head", http.MethodHead, nil, http.StatusOK, ""},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified, ""},
+		{"other etag", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, http.StatusOK, "<!doctype html><p>hello</p>"},
+		{"same date", http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified, ""},
+		{"earlier date", http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, "<!doctype html><p>hello</p>"},
+		{
+			"etag wins over date",
+			http.MethodGet,
+			map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)},
+			http.StatusOK,
+			"<!doctype html><p>hello</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := cacheRequest(handler, tt.method, tt.header)
+			if w.Code != tt.status || w.Body.String() != tt.body {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Body.String(), tt.status, tt.body)
+			}
+			if w.Code == http.StatusNotModified && w.Header().Get("Content-Type") != "" {
+				t.Error("304 response carries a Content-Type")
+			}
+		})
+	}
+	if *renders != 1 {
+		t.Errorf("handler rendered %d times, want 1", *renders)
+	}
+}
+
+func TestCachedInvalidate(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s, handler, renders := cacheTestServer(modified, http.StatusOK, "<p>hello</p>")
+	cacheRequest(handler, http.MethodGet, nil)
+
+	// 任何写入都会推进内容版本，旧的日期不能再得到 304
+	s.cache.invalidate()
+	w := cacheRequest(handler, http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)})
+	if w.Code != http.StatusOK {
+		t.Errorf("status after invalidate = %d, want 200", w.Code)
+	}
+	if *renders != 2 {
+		t.Errorf("handler rendered %d times, want 2", *renders)
+	}
+}
+
+func TestCachedKeepsHandlerLastModified(t *testing.T) {
+	global := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := global.Add(-24 * time.Hour)
+	s := &Server{Config: &config.Config{}, cache: newRenderCache(global)}
+	handler := s.cached(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", post.Format(http.TimeFormat))
+		w.Write([]byte("<p>post</p>"))
+	})
+
+	for i := 0; i < 2; i++ {
+		w := cacheRequest(handler, http.MethodGet, nil)
+		if got := w.Header().Get("Last-Modified"); got != post.Format(http.TimeFormat) {
+			t.Errorf("request %d: Last-Modified = %q, want the handler's time", i+1, got)
+		}
+	}
+	// 其它文章的写入推进了全站时间，但这篇文章的日期仍然有效
+	w := cacheRequest(handler, http.MethodGet, map[string]string{"If-Modified-Since": post.Format(http.TimeFormat)})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional request: %d, want 304", w.Code)
+	}
+}
+
+func TestCachedSkipsErrors(t *testing.T) {
+	_, handler, renders := cacheTestServer(time.Now(), http.StatusNotFound, "not found")
+	for i := 0; i < 2; i++ {
+		if w := cacheRequest(handler, http.MethodGet, nil); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+			t.Errorf("request %d: %d with ETag %q", i+1, w.Code, w.Header().Get("ETag"))
+		}
+	}
+	if *renders != 2 {
+		t.Errorf("handler rendered %d times, want 2", *renders)
+	}
+}
+
+func TestCachedCompressed(t *testing.T) {
+	body := "<!doctype html><p>" + strings.Repeat("hello ", compressMinSize) + "</p>"
+	_, handler, _ := cacheTestServer(time.Now(), http.StatusOK, body)
+
+	plain := cacheRequest(handler, http.MethodGet, nil)
+	w := cacheRequest(handler, http.MethodGet, map[string]string{"Accept-Encoding": "gzip"})
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("compressed Content-Type = %q, want text/html", got)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == plain.Header().Get("ETag") || !strings.HasSuffix(etag, `-gzip"`) {
+		t.Errorf("compressed ETag = %q, plain %q", etag, plain.Header().Get("ETag"))
+	}
+	if w.Body.Len() >= len(body) {
+		t.Errorf("compressed body is %d bytes, plain %d", w.Body.Len(), len(body))
+	}
+
+	again := cacheRequest(handler, http.MethodGet, map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	if again.Code != http.StatusNotModified {
+		t.Errorf("conditional compressed request: %d, want 304", again.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"myblog/internal/blog"
+	"myblog/internal/i18n"
+)
+
+// feedSize is the number of newest posts in a feed.
+const feedSize = 20
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category,omitempty"`
+}
+
+// feedLink is an RSS feed advertised in the page head.
+type feedLink struct {
+	Title string
+	URL   string
+}
+
+// feedLinks lists the site feed, plus one feed per language once posts are
+// written in more than one language.
+func (s *Server) feedLinks(profile blog.SiteProfile) []feedLink {
+	links := []feedLink{{Title: profile.Title, URL: s.Config.SiteBaseURL + "/feed.xml"}}
+	langs := s.FeedLanguages()
+	if len(langs) < 2 {
+		return links
+	}
+	locale := siteLocale(profile)
+	for _, lang := range langs {
+		links = append(links, feedLink{
+			Title: i18n.T(locale, "feed.language", profile.Title, i18n.LanguageName(lang)),
+			URL:   s.Config.SiteBaseURL + "/feeds/" + lang + ".xml",
+		})
+	}
+	return links
+}
+
+// Feed serves the RSS feed of all published posts at /feed.xml.
+func (s *Server) Feed(w http.ResponseWriter, r *http.Request) {
+	profile := s.SiteStore.Get()
+	s.writeFeed(w, "/feed.xml", profile.Title, siteLocale(profile), s.Store.ListPublished())
+}
+
+// LanguageFeed serves /feeds/{lang}.xml with the posts written in one
+// language.
+func (s *Server) LanguageFeed(w http.ResponseWriter, r *http.Request) {
+	lang, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/feeds/"), ".xml")
+	if !ok || !i18n.ValidTag(lang) {
+		http.NotFound(w, r)
+		return
+	}
+	var posts []blog.Post
+	for _, post := range s.Store.ListPublished() {
+		if strings.EqualFold(s.postLang(post), lang) {
+			posts = append(posts, post)
+		}
+	}
+	if len(posts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	profile := s.SiteStore.Get()
+	title := i18n.T(siteLocale(profile), "feed.language", profile.Title, i18n.LanguageName(lang))
+	s.writeFeed(w, r.URL.Path, title, lang, posts)
+}
+
+func (s *Server) writeFeed(w http.ResponseWriter, path, title, lang string, posts []blog.Post) {
+	base := s.Config.SiteBaseURL
+	profile := s.SiteStore.Get()
+	channel := rssChannel{
+		Title:       title,
+		Link:        base + "/",
+		Description: profile.Tagline,
+		Language:    lang,
+		Self:        rssLink{Href: base + path, Rel: "self", Type: "application/rss+xml"},
+	}
+	if len(posts) > feedSize {
+		posts = posts[:feedSize]
+	}
+	var newest time.Time
+	for _, post := range posts {
+		link := base + "/posts/" + post.Slug
+		item := rssItem{
+			Title:       post.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     post.CreatedAt.Format(time.RFC1123Z),
+			Description: post.Summary,
+		}
+		if post.Category != "" {
+			item.Categories = append(item.Categories, post.Category)
+		}
+		item.Categories = append(item.Categories, post.Tags...)
+		channel.Items = append(channel.Items, item)
+		if post.UpdatedAt.After(newest) {
+			newest = post.UpdatedAt
+		}
+	}
+	if !newest.IsZero() {
+		channel.LastBuildDate = newest.Format(time.RFC1123Z)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
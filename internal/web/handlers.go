@@ -1,10 +1,10 @@
 package web
 
 import (
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,13 +12,16 @@ import (
 	"unicode"
 
 	"myblog/internal/blog"
-
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/extension"
-	"github.com/yuin/goldmark/renderer/html"
+	"myblog/internal/i18n"
 )
 
 func (s *Server) Index(w http.ResponseWriter, r *http.Request) {
+	// 其余未匹配的路径交给独立页面
+	if r.URL.Path != "/" && !strings.HasPrefix(r.URL.Path, "/page/") {
+		s.PageDetail(w, r)
+		return
+	}
+
 	page := 1
 	pageSize := IndexPageSize
 	if strings.HasPrefix(r.URL.Path, "/page/") {
@@ -48,7 +51,9 @@ func (s *Server) Index(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tags, categories := collectFilters(s.Store.ListPublished()) // Only collect tags from published
+	// Only collect tags from published posts
+	tags := termNames(s.Store.TagCounts())
+	categories := termNames(s.Store.CategoryCounts())
 	data := s.baseData(r)
 
 	// SEO for Index
@@ -60,6 +65,9 @@ func (s *Server) Index(w http.ResponseWriter, r *http.Request) {
 		// log.Printf("Description set to tagline: %s", data["Tagline"])
 	}
 	data["CurrentPath"] = r.URL.Path
+	if page == 1 {
+		addStructuredData(data, s.homeStructuredData(s.SiteStore.Get(), data["Locale"].(string))...)
+	}
 
 	data["Posts"] = posts
 	data["Featured"] = featured
@@ -86,6 +94,8 @@ func (s *Server) Index(w http.ResponseWriter, r *http.Request) {
 func (s *Server) PostsList(w http.ResponseWriter, r *http.Request) {
 	data := s.baseData(r)
 	data["Posts"] = s.Store.ListPublished()
+	locale := data["Locale"].(string)
+	addStructuredData(data, s.breadcrumbList(locale, breadcrumb{i18n.T(locale, "posts.title"), "/posts"}))
 	s.render(w, "posts.html", data)
 }
 
@@ -111,10 +121,32 @@ func (s *Server) PostDetail(w http.ResponseWriter, r *http.Request) {
 
 	data := s.baseData(r)
 	data["Post"] = post
-	postHTML := renderMarkdown(post.Content)
+	postHTML := post.ContentHTML
+	if postHTML == "" && post.Content != "" {
+		// 尚未预渲染（例如刚导入的数据），临时渲染一次
+		postHTML = renderPost(post, s.Store.GetBySlug, s.shortcodeTemplates()).HTML
+	}
 	postHTML = s.rewriteHTMLAssetURLs(postHTML)
 	data["PostHTML"] = template.HTML(postHTML)
+	data["HasMermaid"] = strings.Contains(postHTML, mermaidOpenTag)
+	backlinks := s.Backlinks(post.Slug)
+	seriesNav := s.seriesNav(post)
 	data["RelatedPosts"] = related
+	data["Backlinks"] = backlinks
+	data["SeriesNav"] = seriesNav
+	data["PostLang"] = s.postLang(post)
+	data["Translations"] = s.translations(post)
+	// 页面上显示的其它文章，用于计算 Last-Modified
+	shown := append(append([]blog.Post{}, related...), backlinks...)
+	shown = append(shown, s.Store.ListTranslations(post.TranslationGroup())...)
+	if prev, ok := s.Store.PrevPost(post.Slug); ok {
+		data["PrevPost"] = prev
+		shown = append(shown, prev)
+	}
+	if next, ok := s.Store.NextPost(post.Slug); ok {
+		data["NextPost"] = next
+		shown = append(shown, next)
+	}
 
 	// SEO Data
 	data["Title"] = post.Title + " - " + data["Title"].(string)
@@ -124,15 +156,105 @@ func (s *Server) PostDetail(w http.ResponseWriter, r *http.Request) {
 	if len(post.Tags) > 0 {
 		data["Keywords"] = strings.Join(post.Tags, ", ")
 	}
-	if post.CoverImage != "" {
-		data["CoverImage"] = post.CoverImage
+	// 没有封面和正文图片时使用自动生成的分享图
+	image := post.CoverImage
+	if image == "" {
+		image = post.FirstImage
+	}
+	if image == "" {
+		image = ogImagePath(post.Slug)
 	}
+	data["CoverImage"] = image
+	data["Outline"] = post.Outline
+	if post.ShowTOC {
+		data["TOC"] = buildTOC(post.Outline)
+	}
+	data["WordCount"] = post.WordCount
 	data["IsPost"] = true
 	data["CurrentPath"] = r.URL.Path
 
+	// 结构化数据：文章本身与 首页 > 文章 > 分类 > 标题 的面包屑
+	locale := data["Locale"].(string)
+	crumbs := []breadcrumb{{i18n.T(locale, "posts.title"), "/posts"}}
+	if post.Category != "" {
+		crumbs = append(crumbs, breadcrumb{post.Category, escapedPath("categories", post.Category)})
+	}
+	crumbs = append(crumbs, breadcrumb{post.Title, escapedPath("posts", post.Slug)})
+	addStructuredData(data,
+		s.postStructuredData(post, s.SiteStore.Get(), data["Canonical"].(string), image),
+		s.breadcrumbList(locale, crumbs...),
+	)
+
+	if lastModified := s.postLastModified(post, seriesNav, shown); !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
 	s.render(w, "post.html", data)
 }
 
+// postLastModified is the latest change to anything a post page shows: the
+// post and its rendered HTML, the other posts it lists, its series, the
+// navigation pages and the site settings. It mirrors the generator's
+// per-post inputs, so editing an unrelated post keeps the page's date.
+func (s *Server) postLastModified(post blog.Post, series *SeriesNav, shown []blog.Post) time.Time {
+	latest := s.SiteStore.UpdatedAt()
+	for _, t := range []time.Time{post.UpdatedAt, post.RenderedAt, newest(shown)} {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	if series != nil {
+		if series.Series.UpdatedAt.After(latest) {
+			latest = series.Series.UpdatedAt
+		}
+		if t := newest(series.Posts); t.After(latest) {
+			latest = t
+		}
+	}
+	for _, page := range s.navPages() {
+		if page.UpdatedAt.After(latest) {
+			latest = page.UpdatedAt
+		}
+	}
+	return latest
+}
+
+func (s *Server) TagPosts(w http.ResponseWriter, r *http.Request) {
+	tag := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags/"), "/")
+	s.renderTermPosts(w, r, "posts.tag_title", tag, s.Store.ListByTag)
+}
+
+func (s *Server) CategoryPosts(w http.ResponseWriter, r *http.Request) {
+	category := strings.Trim(strings.TrimPrefix(r.URL.Path, "/categories/"), "/")
+	s.renderTermPosts(w, r, "posts.category_title", category, s.Store.ListByCategory)
+}
+
+// renderTermPosts lists the posts of a tag or category; titleKey is the
+// message that formats the list title.
+func (s *Server) renderTermPosts(w http.ResponseWriter, r *http.Request, titleKey, term string, list func(string) []blog.Post) {
+	if term == "" {
+		http.NotFound(w, r)
+		return
+	}
+	posts := list(term)
+	if len(posts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := s.baseData(r)
+	data["Posts"] = posts
+	locale := data["Locale"].(string)
+	data["ListTitle"] = i18n.T(locale, titleKey, term)
+	data["ListIntro"] = i18n.T(locale, "posts.count", len(posts))
+	data["Title"] = term + " - " + data["Title"].(string)
+	data["CurrentPath"] = r.URL.Path
+	addStructuredData(data, s.breadcrumbList(locale,
+		breadcrumb{i18n.T(locale, "posts.title"), "/posts"},
+		breadcrumb{term, r.URL.EscapedPath()},
+	))
+	s.render(w, "posts.html", data)
+}
+
 func (s *Server) ArchivePage(w http.ResponseWriter, r *http.Request) {
 	type archiveGroup struct {
 		Title string
@@ -154,6 +276,8 @@ func (s *Server) ArchivePage(w http.ResponseWriter, r *http.Request) {
 
 	data := s.baseData(r)
 	data["Archives"] = groups
+	locale := data["Locale"].(string)
+	addStructuredData(data, s.breadcrumbList(locale, breadcrumb{i18n.T(locale, "archive.title"), "/archive"}))
 	s.render(w, "archive.html", data)
 }
 
@@ -184,33 +308,53 @@ func (s *Server) SearchPage(w http.ResponseWriter, r *http.Request) {
 	s.render(w, "search.html", data)
 }
 
-func collectFilters(posts []blog.Post) ([]string, []string) {
-	tagSet := map[string]struct{}{}
-	categorySet := map[string]struct{}{}
-	for _, post := range posts {
-		if post.Category != "" {
-			categorySet[post.Category] = struct{}{}
-		}
-		for _, tag := range post.Tags {
-			if tag == "" {
-				continue
-			}
-			tagSet[tag] = struct{}{}
+// tocMaxDepth 目录最多展示三级标题，更深的层级在侧栏里没有可读性
+const tocMaxDepth = 3
+
+type tocItem struct {
+	ID    string
+	Text  string
+	Depth int
+}
+
+// buildTOC flattens the heading outline into sidebar entries whose depth is
+// relative to the shallowest heading, so posts starting at h2 still get depth 1.
+func buildTOC(outline []blog.Heading) []tocItem {
+	minLevel := 0
+	for _, h := range outline {
+		if h.ID != "" && (minLevel == 0 || h.Level < minLevel) {
+			minLevel = h.Level
 		}
 	}
 
-	var tags []string
-	for tag := range tagSet {
-		tags = append(tags, tag)
+	var items []tocItem
+	for _, h := range outline {
+		if h.ID == "" {
+			continue
+		}
+		depth := h.Level - minLevel + 1
+		if depth > tocMaxDepth {
+			continue
+		}
+		items = append(items, tocItem{ID: h.ID, Text: h.Text, Depth: depth})
 	}
-	sort.Strings(tags)
+	// 只有一个标题时目录没有意义
+	if len(items) < 2 {
+		return nil
+	}
+	return items
+}
 
-	var categories []string
-	for category := range categorySet {
-		categories = append(categories, category)
+func termNames(terms []blog.TermCount) []string {
+	names := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term.Name == "" {
+			continue
+		}
+		names = append(names, term.Name)
 	}
-	sort.Strings(categories)
-	return tags, categories
+	sort.Strings(names)
+	return names
 }
 
 func (s *Server) AdminPosts(w http.ResponseWriter, r *http.Request) {
@@ -225,6 +369,9 @@ func (s *Server) AdminPosts(w http.ResponseWriter, r *http.Request) {
 
 	data := s.baseData(r)
 	data["Posts"] = posts
+	if n, err := strconv.Atoi(r.URL.Query().Get("rerendered")); err == nil {
+		data["Notice"] = "已重新渲染 " + strconv.Itoa(n) + " 篇文章。"
+	}
 
 	totalPages := (total + pageSize - 1) / pageSize
 	if totalPages < 1 {
@@ -243,26 +390,17 @@ func (s *Server) AdminPosts(w http.ResponseWriter, r *http.Request) {
 func (s *Server) AdminSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		data := s.baseData(r)
-		data["PageTitle"] = "站点设置"
 		profile := s.SiteStore.Get()
-		data["Profile"] = profile
-		data["FocusText"] = strings.Join(profile.CurrentFocus, "\n")
-		data["SkillsText"] = strings.Join(profile.Skills, "\n")
-		data["SocialText"] = socialLinksToText(profile.SocialLinks)
-		s.render(w, "admin_settings.html", data)
+		s.renderSettings(w, r, profile, menuEditors(profile), "")
 	case http.MethodPost:
 		// TODO: Validate CSRF
-		profile := parseSiteForm(r)
-		if err := s.SiteStore.Update(profile); err != nil {
-			data := s.baseData(r)
-			data["PageTitle"] = "站点设置"
-			data["Error"] = err.Error()
-			data["Profile"] = profile
-			data["FocusText"] = strings.Join(profile.CurrentFocus, "\n")
-			data["SkillsText"] = strings.Join(profile.Skills, "\n")
-			data["SocialText"] = socialLinksToText(profile.SocialLinks)
-			s.render(w, "admin_settings.html", data)
+		profile, err := parseSiteForm(r)
+		if err == nil {
+			err = s.SiteStore.Update(profile)
+		}
+		if err != nil {
+			// 保留提交的菜单行，方便直接修正
+			s.renderSettings(w, r, profile, submittedMenuEditors(r), err.Error())
 			return
 		}
 		http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
@@ -271,13 +409,29 @@ func (s *Server) AdminSettings(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) renderSettings(w http.ResponseWriter, r *http.Request, profile blog.SiteProfile, editors []menuEditor, msg string) {
+	data := s.baseData(r)
+	data["PageTitle"] = "站点设置"
+	data["Error"] = msg
+	data["Profile"] = profile
+	data["FocusText"] = strings.Join(profile.CurrentFocus, "\n")
+	data["SkillsText"] = strings.Join(profile.Skills, "\n")
+	data["MenuEditors"] = editors
+	data["Themes"] = AvailableThemes()
+	data["Locales"] = i18n.Locales()
+	data["DefaultRobots"] = defaultRobots
+	data["ConfigTheme"] = s.Config.Theme
+	s.render(w, "admin_settings.html", data)
+}
+
 func (s *Server) AdminPostNew(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		data := s.baseData(r)
 		data["PageTitle"] = "新建文章"
-		data["Post"] = blog.Post{}
+		data["Post"] = blog.Post{ShowTOC: true}
 		data["Action"] = "/admin/posts/new"
+		data["SeriesList"] = s.Series.ListSeries()
 		s.render(w, "admin_form.html", data)
 	case http.MethodPost:
 		post := parsePostForm(r)
@@ -288,6 +442,10 @@ func (s *Server) AdminPostNew(w http.ResponseWriter, r *http.Request) {
 			s.renderAdminFormError(w, r, "新建文章", "需要填写 slug 或者标题包含英文/数字。", post, "/admin/posts/new")
 			return
 		}
+		if msg := s.validatePostLanguage(&post); msg != "" {
+			s.renderAdminFormError(w, r, "新建文章", msg, post, "/admin/posts/new")
+			return
+		}
 		if err := s.Store.Create(post); err != nil {
 			s.renderAdminFormError(w, r, "新建文章", err.Error(), post, "/admin/posts/new")
 			return
@@ -311,6 +469,7 @@ func (s *Server) AdminPostEdit(w http.ResponseWriter, r *http.Request) {
 		data["PageTitle"] = "编辑文章"
 		data["Post"] = post
 		data["Action"] = "/admin/posts/edit?slug=" + slug
+		data["SeriesList"] = s.Series.ListSeries()
 		s.render(w, "admin_form.html", data)
 	case http.MethodPost:
 		slug := r.URL.Query().Get("slug")
@@ -322,6 +481,10 @@ func (s *Server) AdminPostEdit(w http.ResponseWriter, r *http.Request) {
 			s.renderAdminFormError(w, r, "编辑文章", "需要填写 slug 或者标题包含英文/数字。", post, "/admin/posts/edit?slug="+slug)
 			return
 		}
+		if msg := s.validatePostLanguage(&post); msg != "" {
+			s.renderAdminFormError(w, r, "编辑文章", msg, post, "/admin/posts/edit?slug="+slug)
+			return
+		}
 		if err := s.Store.Update(slug, post); err != nil {
 			s.renderAdminFormError(w, r, "编辑文章", err.Error(), post, "/admin/posts/edit?slug="+slug)
 			return
@@ -342,19 +505,58 @@ func (s *Server) AdminPostDelete(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
 }
 
+func (s *Server) AdminRerender(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := s.RerenderAll()
+	if err != nil {
+		log.Printf("Re-render failed after %d post(s): %v", n, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/posts?rerendered="+strconv.Itoa(n), http.StatusSeeOther)
+}
+
+// AdminLinks shows the latest link report; POST starts a new check in the background.
+func (s *Server) AdminLinks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		data := s.baseData(r)
+		data["PageTitle"] = "链接检查"
+		data["Report"], data["Running"] = s.links.get()
+		s.render(w, "admin_links.html", data)
+	case http.MethodPost:
+		s.startLinkCheck(LinkCheckOptions{External: r.FormValue("external") == "on"})
+		http.Redirect(w, r, "/admin/links", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminOrphans lists published posts that no other published post links to.
+func (s *Server) AdminOrphans(w http.ResponseWriter, r *http.Request) {
+	data := s.baseData(r)
+	data["PageTitle"] = "孤立文章"
+	data["Posts"] = s.OrphanPosts()
+	s.render(w, "admin_orphans.html", data)
+}
+
 func (s *Server) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		data := s.baseData(r)
-		data["PageTitle"] = "后台登录"
+		data["PageTitle"] = i18n.T(data["Locale"].(string), "login.title")
 		s.render(w, "admin_login.html", data)
 	case http.MethodPost:
 		user := strings.TrimSpace(r.FormValue("username"))
 		pass := strings.TrimSpace(r.FormValue("password"))
 		if !s.validAdminCredentials(user, pass) {
 			data := s.baseData(r)
-			data["PageTitle"] = "后台登录"
-			data["Error"] = "账号或密码错误"
+			locale := data["Locale"].(string)
+			data["PageTitle"] = i18n.T(locale, "login.title")
+			data["Error"] = i18n.T(locale, "login.failed")
 			s.render(w, "admin_login.html", data)
 			return
 		}
@@ -379,21 +581,27 @@ func (s *Server) AdminLogout(w http.ResponseWriter, r *http.Request) {
 func parsePostForm(r *http.Request) blog.Post {
 	_ = r.ParseForm()
 	return blog.Post{
-		Title:      strings.TrimSpace(r.FormValue("title")),
-		Slug:       strings.TrimSpace(r.FormValue("slug")),
-		Summary:    strings.TrimSpace(r.FormValue("summary")),
-		Content:    strings.TrimSpace(r.FormValue("content")),
-		Category:   strings.TrimSpace(r.FormValue("category")),
-		Tags:       splitComma(r.FormValue("tags")),
-		CoverImage: strings.TrimSpace(r.FormValue("cover_image")),
-		Featured:   r.FormValue("featured") == "on",
-		IsDraft:    r.FormValue("is_draft") == "on",
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Slug:        strings.TrimSpace(r.FormValue("slug")),
+		Summary:     strings.TrimSpace(r.FormValue("summary")),
+		Content:     strings.TrimSpace(r.FormValue("content")),
+		Category:    strings.TrimSpace(r.FormValue("category")),
+		Tags:        splitComma(r.FormValue("tags")),
+		CoverImage:  strings.TrimSpace(r.FormValue("cover_image")),
+		Featured:    r.FormValue("featured") == "on",
+		IsDraft:     r.FormValue("is_draft") == "on",
+		ShowTOC:     r.FormValue("show_toc") == "on",
+		Series:      strings.TrimSpace(r.FormValue("series")),
+		SeriesOrder: parseOrder(r.FormValue("series_order")),
+
+		Lang:          strings.TrimSpace(r.FormValue("lang")),
+		TranslationOf: strings.TrimSpace(r.FormValue("translation_of")),
 	}
 }
 
-func parseSiteForm(r *http.Request) blog.SiteProfile {
+func parseSiteForm(r *http.Request) (blog.SiteProfile, error) {
 	_ = r.ParseForm()
-	return blog.SiteProfile{
+	profile := blog.SiteProfile{
 		Title:        strings.TrimSpace(r.FormValue("title")),
 		Tagline:      strings.TrimSpace(r.FormValue("tagline")),
 		Intro:        strings.TrimSpace(r.FormValue("intro")),
@@ -408,8 +616,29 @@ func parseSiteForm(r *http.Request) blog.SiteProfile {
 		Email:        strings.TrimSpace(r.FormValue("email")),
 		Newsletter:   strings.TrimSpace(r.FormValue("newsletter")),
 		CurrentFocus: splitLines(strings.TrimSpace(r.FormValue("current_focus"))),
-		SocialLinks:  parseSocialLinks(strings.TrimSpace(r.FormValue("social_links"))),
+		Menus:        map[string][]blog.MenuItem{},
+		Theme:        strings.TrimSpace(r.FormValue("theme")),
+		Locale:       i18n.Normalize(r.FormValue("locale")),
+		Robots:       strings.TrimSpace(strings.ReplaceAll(r.FormValue("robots"), "\r\n", "\n")),
+	}
+	if profile.Theme != "" {
+		if _, err := loadTheme(profile.Theme); err != nil {
+			return profile, fmt.Errorf("主题「%s」不可用：themes/%s/theme.json 不存在或格式不正确", profile.Theme, profile.Theme)
+		}
+	}
+	for _, m := range editableMenus {
+		items, err := buildMenu(m.Label, parseMenuRows(r, "menu_"+m.Name))
+		if err != nil {
+			return profile, err
+		}
+		profile.Menus[m.Name] = items
+	}
+	links, err := parseSocialRows(parseMenuRows(r, "social"))
+	if err != nil {
+		return profile, err
 	}
+	profile.SocialLinks = links
+	return profile, nil
 }
 
 func (s *Server) renderAdminFormError(w http.ResponseWriter, r *http.Request, pageTitle, msg string, post blog.Post, action string) {
@@ -418,100 +647,10 @@ func (s *Server) renderAdminFormError(w http.ResponseWriter, r *http.Request, pa
 	data["Error"] = msg
 	data["Post"] = post
 	data["Action"] = action
+	data["SeriesList"] = s.Series.ListSeries()
 	s.render(w, "admin_form.html", data)
 }
 
-func (s *Server) render(w http.ResponseWriter, page string, data map[string]any) {
-	t, err := s.templateFor(page)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := t.ExecuteTemplate(w, "base", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (s *Server) templateFor(page string) (*template.Template, error) {
-	s.TemplateCache = ensureCache(s.TemplateCache)
-	if t, ok := s.TemplateCache[page]; ok {
-		return t, nil
-	}
-
-	files := []string{
-		"internal/web/templates/base.html",
-		"internal/web/templates/" + page,
-	}
-	if page == "search.html" {
-		files = append(files, "internal/web/templates/search_results.html")
-	}
-
-	t, err := template.New("").Funcs(template.FuncMap{
-		"assetURL": func(input string) string {
-			return s.assetURL(input)
-		},
-		"formatDate": func(t time.Time) string {
-			if t.IsZero() {
-				return ""
-			}
-			return t.Format("2006-01-02")
-		},
-		"lower": func(input string) string {
-			return strings.ToLower(input)
-		},
-		"joinTags": func(tags []string) string {
-			return strings.Join(tags, ",")
-		},
-		"add": func(a, b int) int {
-			return a + b
-		},
-	}).ParseFiles(files...)
-	if err != nil {
-		return nil, err
-	}
-	s.TemplateCache[page] = t
-	return t, nil
-}
-
-func (s *Server) renderPartial(w http.ResponseWriter, page string, data map[string]any) {
-	t, err := template.New(filepath.Base(page)).Funcs(template.FuncMap{
-		"assetURL": func(input string) string {
-			return s.assetURL(input)
-		},
-		"formatDate": func(t time.Time) string {
-			if t.IsZero() {
-				return ""
-			}
-			return t.Format("2006-01-02")
-		},
-		"lower": func(input string) string {
-			return strings.ToLower(input)
-		},
-		"joinTags": func(tags []string) string {
-			return strings.Join(tags, ",")
-		},
-		"add": func(a, b int) int {
-			return a + b
-		},
-	}).ParseFiles("internal/web/templates/" + page)
-	if err != nil {
-		log.Printf("Template parse error: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := t.Execute(w, data); err != nil {
-		log.Printf("Template execution error: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func ensureCache(cache map[string]*template.Template) map[string]*template.Template {
-	if cache == nil {
-		return make(map[string]*template.Template)
-	}
-	return cache
-}
-
 func slugify(input string) string {
 	var b strings.Builder
 	lastDash := false
@@ -543,7 +682,14 @@ func (s *Server) baseData(r *http.Request) map[string]any {
 		heroBioHTML = template.HTML(rendered)
 	}
 
+	locale := s.locale(r)
 	return map[string]any{
+		"Locale":       locale,
+		"Feeds":        s.feedLinks(profile),
+		"Canonical":    s.canonicalURL(r),
+		"SiteName":     profile.Title,
+		"OGLocale":     ogLocale(locale),
+		"TwitterSite":  twitterHandle(profile),
 		"Title":        profile.Title,
 		"Tagline":      profile.Tagline,
 		"Intro":        profile.Intro,
@@ -560,6 +706,8 @@ func (s *Server) baseData(r *http.Request) map[string]any {
 		"Newsletter":   profile.Newsletter,
 		"CurrentFocus": profile.CurrentFocus,
 		"SocialLinks":  profile.SocialLinks,
+		"HeaderMenu":   s.menu(locale, profile, blog.MenuHeader),
+		"FooterMenu":   s.menu(locale, profile, blog.MenuFooter),
 		"SiteURL":      s.Config.SiteBaseURL,
 		"AdminURL":     s.Config.AdminBaseURL,
 		"CSRFToken":    getCsrfToken(r),
@@ -567,7 +715,7 @@ func (s *Server) baseData(r *http.Request) map[string]any {
 }
 
 func (s *Server) assetURL(raw string) string {
-	return resolveAssetURL(s.Config.SiteBaseURL, raw)
+	return resolveAssetURL(s.Config.SiteBaseURL, s.fingerprintURL(strings.TrimSpace(raw)))
 }
 
 func (s *Server) rewriteHTMLAssetURLs(input string) string {
@@ -633,45 +781,6 @@ func resolveAssetURL(base, raw string) string {
 	return raw
 }
 
-func parseSocialLinks(input string) []blog.SocialLink {
-	if input == "" {
-		return nil
-	}
-	lines := strings.Split(input, "\n")
-	var links []blog.SocialLink
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		parts := strings.SplitN(line, "|", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		name := strings.TrimSpace(parts[0])
-		url := strings.TrimSpace(parts[1])
-		if name == "" || url == "" {
-			continue
-		}
-		links = append(links, blog.SocialLink{Name: name, URL: url})
-	}
-	return links
-}
-
-func socialLinksToText(links []blog.SocialLink) string {
-	if len(links) == 0 {
-		return ""
-	}
-	lines := make([]string, 0, len(links))
-	for _, link := range links {
-		if link.Name == "" || link.URL == "" {
-			continue
-		}
-		lines = append(lines, link.Name+"|"+link.URL)
-	}
-	return strings.Join(lines, "\n")
-}
-
 func parseFloatDefault(val string, def float64) float64 {
 	if val == "" {
 		return def
@@ -693,21 +802,6 @@ func clampFloat(v, min, max float64) float64 {
 	return v
 }
 
-func renderMarkdown(input string) string {
-	if strings.TrimSpace(input) == "" {
-		return ""
-	}
-	var b strings.Builder
-	md := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
-		goldmark.WithRendererOptions(html.WithUnsafe()),
-	)
-	if err := md.Convert([]byte(input), &b); err != nil {
-		return input
-	}
-	return b.String()
-}
-
 func splitComma(input string) []string {
 	parts := strings.Split(input, ",")
 	var result []string
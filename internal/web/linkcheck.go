@@ -0,0 +1,382 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 外部链接检查的默认并发数与单个请求超时
+const (
+	defaultLinkCheckConcurrency = 8
+	defaultLinkCheckTimeout     = 10 * time.Second
+	// 后台检查整体的时间上限，超时后未完成的外部请求按失败记录
+	linkCheckDeadline = 5 * time.Minute
+)
+
+// LinkCheckOptions controls a link-checking pass.
+type LinkCheckOptions struct {
+	// External also requests http(s) links to other sites.
+	External bool
+	// Concurrency limits simultaneous external requests.
+	Concurrency int
+	// Timeout applies to each external request.
+	Timeout time.Duration
+}
+
+// BrokenLink is one link in a post whose target could not be found.
+type BrokenLink struct {
+	URL    string
+	Text   string
+	Reason string
+}
+
+// PostLinks lists the broken links found in one post.
+type PostLinks struct {
+	Slug  string
+	Title string
+	Links []BrokenLink
+}
+
+// LinkReport is the result of checking the links of every published post.
+type LinkReport struct {
+	CheckedAt time.Time
+	Duration  time.Duration
+	External  bool
+	Posts     int
+	Links     int
+	Broken    []PostLinks
+}
+
+// BrokenCount is the total number of broken links across all posts.
+func (r *LinkReport) BrokenCount() int {
+	n := 0
+	for _, p := range r.Broken {
+		n += len(p.Links)
+	}
+	return n
+}
+
+// pageLink is a link found in rendered HTML.
+type pageLink struct {
+	URL  string
+	Text string
+}
+
+// CheckLinks parses the rendered HTML of every published post and verifies internal
+// /posts/ and /uploads/ targets against the store and the uploads directory.
+// With opts.External, http(s) links to other hosts are requested as well,
+// each distinct URL once. Drafts are skipped: they are not on the site yet.
+func (s *Server) CheckLinks(ctx context.Context, opts LinkCheckOptions) *LinkReport {
+	start := time.Now()
+	report := &LinkReport{CheckedAt: start, External: opts.External}
+
+	type pending struct {
+		post int
+		link pageLink
+	}
+	var results []PostLinks
+	var external []pending
+	for _, post := range s.Store.ListPublished() {
+		content := post.ContentHTML
+		if content == "" && post.Content != "" {
+			content = renderPost(post, s.Store.GetBySlug, s.shortcodeTemplates()).HTML
+		}
+		entry := PostLinks{Slug: post.Slug, Title: post.Title}
+		for _, link := range extractLinks(content) {
+			report.Links++
+			target, internal := s.internalPath(link.URL)
+			switch {
+			case internal:
+				if reason := s.checkInternalLink(target); reason != "" {
+					entry.Links = append(entry.Links, BrokenLink{URL: link.URL, Text: link.Text, Reason: reason})
+				}
+			case opts.External && isExternalURL(link.URL):
+				external = append(external, pending{post: len(results), link: link})
+			}
+		}
+		results = append(results, entry)
+		report.Posts++
+	}
+
+	if len(external) > 0 {
+		urls := make([]string, 0, len(external))
+		for _, p := range external {
+			urls = append(urls, p.link.URL)
+		}
+		failures := checkExternalLinks(ctx, urls, opts)
+		for _, p := range external {
+			if reason, ok := failures[p.link.URL]; ok {
+				results[p.post].Links = append(results[p.post].Links, BrokenLink{URL: p.link.URL, Text: p.link.Text, Reason: reason})
+			}
+		}
+	}
+
+	for _, entry := range results {
+		if len(entry.Links) > 0 {
+			report.Broken = append(report.Broken, entry)
+		}
+	}
+	report.Duration = time.Since(start)
+	return report
+}
+
+// internalPath returns the site path of a link that points into this site,
+// accepting both root-relative links and absolute links under SiteBaseURL.
+func (s *Server) internalPath(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if base := normalizeBaseURL(s.Config.SiteBaseURL); base != "" && strings.HasPrefix(raw, base+"/") {
+		raw = strings.TrimPrefix(raw, base)
+	}
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// checkInternalLink returns why an internal path is broken, or "" if it is fine.
+// Paths other than /posts/ and /uploads/ are not checked.
+func (s *Server) checkInternalLink(p string) string {
+	switch {
+	case strings.HasPrefix(p, "/posts/"):
+		slug := strings.Trim(strings.TrimPrefix(p, "/posts/"), "/")
+		if slug == "" {
+			return ""
+		}
+		post, ok := s.Store.GetBySlug(slug)
+		if !ok {
+			return "文章不存在"
+		}
+		if post.IsDraft {
+			return "文章未发布"
+		}
+	case strings.HasPrefix(p, "/uploads/"):
+		rel := strings.TrimPrefix(path.Clean(p), "/uploads/")
+		info, err := os.Stat(filepath.Join("uploads", filepath.FromSlash(rel)))
+		if err != nil {
+			return "文件不存在"
+		}
+		if info.IsDir() {
+			return "路径是目录"
+		}
+	}
+	return ""
+}
+
+func isExternalURL(raw string) bool {
+	return strings.HasPrefix(raw, "http://") || strings.HasPrefix(raw, "https://")
+}
+
+// checkExternalLinks requests each distinct URL with at most opts.Concurrency
+// requests in flight and returns the failure reason of every broken one.
+func checkExternalLinks(ctx context.Context, urls []string, opts LinkCheckOptions) map[string]string {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultLinkCheckConcurrency
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultLinkCheckTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	seen := map[string]bool{}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = map[string]string{}
+		sem      = make(chan struct{}, concurrency)
+	)
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if reason := checkExternalLink(ctx, client, u); reason != "" {
+				mu.Lock()
+				failures[u] = reason
+				mu.Unlock()
+			}
+		}(u)
+	}
+	wg.Wait()
+	return failures
+}
+
+// checkExternalLink tries HEAD first and falls back to GET, since some
+// servers reject HEAD requests.
+func checkExternalLink(ctx context.Context, client *http.Client, u string) string {
+	status, err := requestStatus(ctx, client, http.MethodHead, u)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusForbidden || status == http.StatusNotImplemented) {
+		status, err = requestStatus(ctx, client, http.MethodGet, u)
+	}
+	if err != nil {
+		return "请求失败：" + err.Error()
+	}
+	if status >= 400 {
+		return fmt.Sprintf("HTTP %d", status)
+	}
+	return ""
+}
+
+func requestStatus(ctx context.Context, client *http.Client, method, u string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "myblog-linkcheck/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// extractLinks returns the targets of a[href] and the sources of images and
+// media in an HTML fragment, skipping in-page anchors and non-web schemes.
+func extractLinks(fragment string) []pageLink {
+	if strings.TrimSpace(fragment) == "" {
+		return nil
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return nil
+	}
+
+	var links []pageLink
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attr := ""
+			switch n.Data {
+			case "a":
+				attr = "href"
+			case "img", "source", "video", "audio", "iframe":
+				attr = "src"
+			}
+			if attr != "" {
+				if target := htmlAttr(n, attr); isCheckableLink(target) {
+					text := strings.TrimSpace(nodeText(n))
+					if text == "" {
+						text = htmlAttr(n, "alt")
+					}
+					links = append(links, pageLink{URL: target, Text: text})
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return links
+}
+
+func isCheckableLink(target string) bool {
+	target = strings.TrimSpace(target)
+	if target == "" || strings.HasPrefix(target, "#") {
+		return false
+	}
+	for _, scheme := range []string{"mailto:", "tel:", "data:", "javascript:"} {
+		if strings.HasPrefix(strings.ToLower(target), scheme) {
+			return false
+		}
+	}
+	return true
+}
+
+func htmlAttr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// lastLinkReport keeps the most recent report for the admin page and
+// tracks whether a check started from the admin is still running.
+type lastLinkReport struct {
+	mu      sync.Mutex
+	report  *LinkReport
+	running bool
+}
+
+func (l *lastLinkReport) get() (*LinkReport, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.report, l.running
+}
+
+// start marks a check as running; it returns false if one already is.
+func (l *lastLinkReport) start() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running {
+		return false
+	}
+	l.running = true
+	return true
+}
+
+func (l *lastLinkReport) finish(r *LinkReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.report = r
+	l.running = false
+}
+
+// startLinkCheck runs a link check in the background, so the admin request
+// does not wait for external sites. The check stops after linkCheckDeadline.
+// It returns false if a check is already running.
+func (s *Server) startLinkCheck(opts LinkCheckOptions) bool {
+	if !s.links.start() {
+		return false
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), linkCheckDeadline)
+		defer cancel()
+		report := s.CheckLinks(ctx, opts)
+		log.Printf("Link check finished: %d broken link(s) in %s", report.BrokenCount(), report.Duration.Round(time.Millisecond))
+		s.links.finish(report)
+	}()
+	return true
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"myblog/internal/blog"
+	"myblog/internal/i18n"
+)
+
+// siteLocale is the UI locale chosen in the site settings.
+func siteLocale(profile blog.SiteProfile) string {
+	if code := i18n.Normalize(profile.Locale); code != "" {
+		return code
+	}
+	return i18n.DefaultLocale
+}
+
+// locale picks the UI locale of a request: the best supported match for the
+// visitor's Accept-Language, else the site locale.
+func (s *Server) locale(r *http.Request) string {
+	if code, ok := i18n.Match(r.Header.Get("Accept-Language")); ok {
+		return code
+	}
+	return siteLocale(s.SiteStore.Get())
+}
+
+// postLang is the content language of a post; posts without one are in the
+// site's language.
+func (s *Server) postLang(post blog.Post) string {
+	if post.Lang != "" {
+		return post.Lang
+	}
+	return siteLocale(s.SiteStore.Get())
+}
+
+// translationLink points to one language version of a post.
+type translationLink struct {
+	Lang    string
+	Name    string
+	URL     string
+	Current bool
+}
+
+// translations lists every published language version of a post, including
+// the post itself, or nil when it has none.
+func (s *Server) translations(post blog.Post) []translationLink {
+	group := s.Store.ListTranslations(post.TranslationGroup())
+	if len(group) < 2 {
+		return nil
+	}
+	links := make([]translationLink, 0, len(group))
+	for _, p := range group {
+		lang := s.postLang(p)
+		links = append(links, translationLink{
+			Lang:    lang,
+			Name:    i18n.LanguageName(lang),
+			URL:     s.Config.SiteBaseURL + "/posts/" + p.Slug,
+			Current: p.Slug == post.Slug,
+		})
+	}
+	return links
+}
+
+// validatePostLanguage checks the language fields of a submitted post and
+// points TranslationOf at the original even when a translation was picked.
+func (s *Server) validatePostLanguage(post *blog.Post) string {
+	if post.Lang != "" && !i18n.ValidTag(post.Lang) {
+		return "语言代码「" + post.Lang + "」格式不正确，例如 en、zh-CN、ja"
+	}
+	if post.TranslationOf == "" {
+		return ""
+	}
+	original, ok := s.Store.GetBySlug(post.TranslationOf)
+	if !ok {
+		return "原文「" + post.TranslationOf + "」不存在"
+	}
+	if original.TranslationOf != "" {
+		post.TranslationOf = original.TranslationOf
+	}
+	if post.TranslationOf == post.Slug {
+		return "文章不能是自己的译文"
+	}
+	return ""
+}
+
+// FeedLanguages lists the content languages of published posts, for the
+// per-language feeds.
+func (s *Server) FeedLanguages() []string {
+	seen := map[string]bool{}
+	var langs []string
+	for _, post := range s.Store.ListPublished() {
+		lang := s.postLang(post)
+		if key := strings.ToLower(lang); !seen[key] {
+			seen[key] = true
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"myblog/internal/blog"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdown 在进程内只构建一次，goldmark 实例可以被并发使用
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.Footnote,
+		extension.DefinitionList,
+		headingAnchors,
+		codeHighlighting,
+		mathAndDiagrams,
+		shortcodes,
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var imgSrcPattern = regexp.MustCompile(`<img[^>]+src=["']([^"']+)["']`)
+
+func renderMarkdown(input string) string {
+	if strings.TrimSpace(input) == "" {
+		return ""
+	}
+	var b strings.Builder
+	if err := markdown.Convert([]byte(input), &b, newParseContext(nil)); err != nil {
+		return input
+	}
+	return b.String()
+}
+
+// renderPost renders a post's Markdown and derives plain text, word count,
+// heading outline and the first image. lookup resolves {{< post >}} shortcodes.
+func renderPost(post blog.Post, lookup postLookup, shortcodes *template.Template) blog.Rendered {
+	if strings.TrimSpace(post.Content) == "" {
+		return blog.Rendered{}
+	}
+
+	src := []byte(post.Content)
+	doc := markdown.Parser().Parse(text.NewReader(src), newParseContext(&shortcodeState{lookup: lookup, templates: shortcodes}))
+
+	var out bytes.Buffer
+	if err := markdown.Renderer().Render(&out, src, doc); err != nil {
+		return blog.Rendered{HTML: post.Content, Text: post.Content}
+	}
+
+	rendered := blog.Rendered{HTML: out.String()}
+	var plain strings.Builder
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				plain.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			heading := blog.Heading{Level: node.Level, Text: inlineText(node, src)}
+			if id, ok := node.AttributeString("id"); ok {
+				if b, ok := id.([]byte); ok {
+					heading.ID = string(b)
+				}
+			}
+			rendered.Outline = append(rendered.Outline, heading)
+		case *ast.Image:
+			if rendered.FirstImage == "" {
+				rendered.FirstImage = string(node.Destination)
+			}
+		case *ast.Text:
+			plain.Write(node.Segment.Value(src))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				plain.WriteByte(' ')
+			}
+		case *ast.String:
+			plain.Write(node.Value)
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				seg := lines.At(i)
+				plain.Write(seg.Value(src))
+			}
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	rendered.Text = collapseBlankLines(plain.String())
+	latin, cjk := blog.CountWords(rendered.Text)
+	rendered.WordCount = latin + cjk
+
+	// 正文里直接写的 <img> 标签不在 Markdown AST 中，用渲染结果兜底
+	if rendered.FirstImage == "" {
+		if m := imgSrcPattern.FindStringSubmatch(rendered.HTML); m != nil {
+			rendered.FirstImage = m[1]
+		}
+	}
+	return rendered
+}
+
+// inlineText concatenates the text content of a node's inline children.
+func inlineText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := c.(type) {
+		case *ast.Text:
+			b.Write(node.Segment.Value(src))
+		case *ast.String:
+			b.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+func collapseBlankLines(input string) string {
+	lines := strings.Split(input, "\n")
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// newParseContext gives every document its own heading ID table,
+// so IDs are stable per post and unique within it, and its shortcode state.
+func newParseContext(state *shortcodeState) parser.ParseOption {
+	ctx := parser.NewContext(parser.WithIDs(&headingIDs{values: map[string]bool{}}))
+	if state != nil {
+		ctx.Set(shortcodeStateKey, state)
+	}
+	return parser.WithContext(ctx)
+}
+
+// headingIDs generates heading IDs that keep CJK characters, e.g.
+// "二、 并发编程 (Concurrency)" becomes "二-并发编程-concurrency".
+// goldmark's default drops non-ASCII text and leaves "heading-1" style IDs.
+type headingIDs struct {
+	values map[string]bool
+}
+
+func (ids *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	base := slugify(string(value))
+	if base == "" {
+		base = "section"
+	}
+	id := base
+	for i := 1; ids.values[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	ids.values[id] = true
+	return []byte(id)
+}
+
+func (ids *headingIDs) Put(value []byte) {
+	ids.values[string(value)] = true
+}
+
+// headingAnchors appends a permalink anchor to every heading that has an ID.
+var headingAnchors = &headingAnchorExtension{}
+
+type headingAnchorExtension struct{}
+
+func (e *headingAnchorExtension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&headingAnchorRenderer{}, 100),
+	))
+}
+
+type headingAnchorRenderer struct{}
+
+func (r *headingAnchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r *headingAnchorRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	level := "0123456"[n.Level]
+	if entering {
+		_, _ = w.WriteString("<h")
+		_ = w.WriteByte(level)
+		if n.Attributes() != nil {
+			html.RenderAttributes(w, node, html.HeadingAttributeFilter)
+		}
+		_ = w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+
+	if id, ok := n.AttributeString("id"); ok {
+		if b, ok := id.([]byte); ok && len(b) > 0 {
+			_, _ = w.WriteString(`<a class="heading-anchor" href="#`)
+			_, _ = w.Write(util.EscapeHTML(util.URLEscape(b, false)))
+			_, _ = w.WriteString(`">#</a>`)
+		}
+	}
+	_, _ = w.WriteString("</h")
+	_ = w.WriteByte(level)
+	_, _ = w.WriteString(">\n")
+	return ast.WalkContinue, nil
+}
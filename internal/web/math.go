@@ -0,0 +1,252 @@
+package web
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// mermaidOpenTag marks a diagram in rendered HTML; post pages load the
+// mermaid script only when their content contains it.
+const mermaidOpenTag = `<pre class="mermaid">`
+
+// mathAndDiagrams adds $...$ inline math, $$...$$ display math and
+// ```mermaid diagrams. Math is converted to MathML on the server so pages
+// (and the static build) need no client script; mermaid source is emitted
+// in a <pre class="mermaid"> for the mermaid script to pick up.
+var mathAndDiagrams = &mathExtension{}
+
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 90)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 90)),
+		parser.WithASTTransformers(util.Prioritized(&mermaidTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&mathRenderer{}, 100),
+	))
+}
+
+var (
+	kindMath         = ast.NewNodeKind("Math")
+	kindMathBlock    = ast.NewNodeKind("MathBlock")
+	kindMermaidBlock = ast.NewNodeKind("MermaidBlock")
+)
+
+// mathInline is $...$ (or $$...$$ inside a paragraph).
+type mathInline struct {
+	ast.BaseInline
+	Value   []byte
+	Display bool
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMath }
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": string(n.Value)}, nil)
+}
+
+// mathBlock is a $$ ... $$ block on its own lines.
+type mathBlock struct {
+	ast.BaseBlock
+	closed bool
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlock) IsRaw() bool { return true }
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mermaidBlock replaces a ```mermaid fenced code block.
+type mermaidBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mermaidBlock) Kind() ast.NodeKind { return kindMermaidBlock }
+
+func (n *mermaidBlock) IsRaw() bool { return true }
+
+func (n *mermaidBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse follows the Pandoc rules so prices like "$5 and $10" stay text:
+// an opening single $ must not be followed by a space, and the closing $
+// must not follow a space or be followed by a digit. A $ after a space
+// ends the search, so "$5 and $x$" still finds the formula $x$.
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	body := line[delim:]
+	if len(body) == 0 || (delim == 1 && util.IsSpace(body[0])) {
+		return nil
+	}
+
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '$':
+			if delim == 2 {
+				if i+1 < len(body) && body[i+1] == '$' && i > 0 {
+					block.Advance(2 + i + 2)
+					return &mathInline{Value: body[:i], Display: true}
+				}
+				continue
+			}
+			// 前面是空白的 $ 更像下一段公式的开头，放弃当前匹配
+			if i == 0 || util.IsSpace(body[i-1]) {
+				return nil
+			}
+			if i+1 < len(body) && body[i+1] >= '0' && body[i+1] <= '9' {
+				continue
+			}
+			block.Advance(1 + i + 1)
+			return &mathInline{Value: body[:i]}
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Open starts a block on a line that is "$$" alone or a complete "$$ ... $$".
+// Any other line starting with $$ is left to the paragraph and inline parser.
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	start := pos + 2
+	rest := util.TrimRightSpace(line[start:])
+	node := &mathBlock{}
+	switch {
+	case len(util.TrimLeftSpace(rest)) == 0:
+	case len(rest) > 2 && bytes.HasSuffix(rest, []byte("$$")):
+		stop := start + len(rest) - 2
+		node.Lines().Append(text.NewSegment(segment.Start+start, segment.Start+stop))
+		node.closed = true
+	default:
+		return nil, parser.NoChildren
+	}
+	reader.Advance(segment.Len() - 1)
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	block := node.(*mathBlock)
+	if block.closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if trimmed := util.TrimRightSpace(line); bytes.HasSuffix(trimmed, []byte("$$")) {
+		if stop := len(trimmed) - 2; stop > 0 {
+			node.Lines().Append(text.NewSegment(segment.Start, segment.Start+stop))
+		}
+		reader.Advance(segment.Len() - 1)
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// mermaidTransformer swaps ```mermaid code blocks for mermaidBlock nodes
+// before rendering, so the syntax highlighter never sees them.
+type mermaidTransformer struct{}
+
+func (t *mermaidTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var blocks []*ast.FencedCodeBlock
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if code, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			if string(code.Language(source)) == "mermaid" {
+				blocks = append(blocks, code)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, code := range blocks {
+		diagram := &mermaidBlock{}
+		diagram.SetLines(code.Lines())
+		code.Parent().ReplaceChild(code.Parent(), code, diagram)
+	}
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, r.renderMath)
+	reg.Register(kindMathBlock, r.renderMathBlock)
+	reg.Register(kindMermaidBlock, r.renderMermaid)
+}
+
+func (r *mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*mathInline)
+		_, _ = w.WriteString(latexToMathML(string(n.Value), n.Display))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(latexToMathML(string(nodeLines(node, source)), true))
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMermaid(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(mermaidOpenTag)
+		_, _ = w.Write(util.EscapeHTML(nodeLines(node, source)))
+		_, _ = w.WriteString("</pre>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func nodeLines(node ast.Node, source []byte) []byte {
+	var b bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		b.Write(seg.Value(source))
+	}
+	return b.Bytes()
+}
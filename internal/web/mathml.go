@@ -0,0 +1,840 @@
+package web
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// latexToMathML converts a LaTeX math expression to presentation MathML.
+// It covers the subset used in posts: scripts, fractions, roots, Greek letters,
+// operators, accents, font styles, \left...\right fences and matrix-like
+// environments. Unknown commands become <merror> instead of failing the page,
+// and the original source is kept as a TeX annotation.
+func latexToMathML(tex string, display bool) string {
+	p := &mathParser{src: tex, display: display}
+	body := p.parseTop()
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics>")
+	b.WriteString(body)
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String()
+}
+
+type mathTokenKind int
+
+const (
+	mathEOF mathTokenKind = iota
+	mathCommand
+	mathLetter
+	mathNumber
+	mathSymbol
+	mathOpen
+	mathClose
+	mathSup
+	mathSub
+	mathAmp
+	mathNewline
+	mathTilde
+)
+
+type mathToken struct {
+	kind  mathTokenKind
+	text  string
+	start int
+	end   int
+}
+
+// 上下标的排布方式：普通、仅在行间公式中放在上下方（\sum）、总是放在上下方（\underbrace）
+type mathLimits int
+
+const (
+	limitsNone mathLimits = iota
+	limitsDisplay
+	limitsAlways
+)
+
+type mathParser struct {
+	src     string
+	pos     int
+	display bool
+	variant string
+	depth   int
+}
+
+// next reads one token, skipping whitespace and % comments.
+func (p *mathParser) next() mathToken {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if unicode.IsSpace(r) {
+			p.pos += size
+			continue
+		}
+		if r == '%' {
+			if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+				p.pos += i + 1
+			} else {
+				p.pos = len(p.src)
+			}
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		return mathToken{kind: mathEOF, start: start, end: start}
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	tok := mathToken{text: string(r), start: start}
+	switch {
+	case r == '\\':
+		if p.pos >= len(p.src) {
+			tok.kind = mathSymbol
+			break
+		}
+		end := p.pos
+		for end < len(p.src) && isASCIILetter(p.src[end]) {
+			end++
+		}
+		if end == p.pos {
+			// 单个非字母字符的控制序列，例如 \, \{ \\
+			_, n := utf8.DecodeRuneInString(p.src[p.pos:])
+			end = p.pos + n
+		}
+		tok.text = p.src[p.pos:end]
+		tok.kind = mathCommand
+		if tok.text == "\\" {
+			tok.kind = mathNewline
+		}
+		p.pos = end
+	case r == '{':
+		tok.kind = mathOpen
+	case r == '}':
+		tok.kind = mathClose
+	case r == '^':
+		tok.kind = mathSup
+	case r == '_':
+		tok.kind = mathSub
+	case r == '&':
+		tok.kind = mathAmp
+	case r == '~':
+		tok.kind = mathTilde
+	case r >= '0' && r <= '9':
+		end := p.pos
+		for end < len(p.src) && (isASCIIDigit(p.src[end]) ||
+			(p.src[end] == '.' && end+1 < len(p.src) && isASCIIDigit(p.src[end+1]))) {
+			end++
+		}
+		tok.text = p.src[start:end]
+		tok.kind = mathNumber
+		p.pos = end
+	case unicode.IsLetter(r):
+		tok.kind = mathLetter
+	default:
+		tok.kind = mathSymbol
+	}
+	tok.end = p.pos
+	return tok
+}
+
+func (p *mathParser) peek() mathToken {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+// parseTop parses the whole expression. A top-level \\ or & turns it into a table,
+// the way multi-line display math is usually written.
+func (p *mathParser) parseTop() string {
+	rows := p.parseTable()
+	if len(rows) == 1 && len(rows[0]) == 1 {
+		return rows[0][0]
+	}
+	return mathTable(rows, "", true)
+}
+
+// parseRow parses atoms until a token that ends the current row or group.
+// Unbalanced closers at the top level are reported and skipped.
+func (p *mathParser) parseRow(stop string) []string {
+	var nodes []string
+	for {
+		tok := p.peek()
+		switch tok.kind {
+		case mathEOF, mathAmp, mathNewline:
+			return nodes
+		case mathClose:
+			if p.depth > 0 {
+				return nodes
+			}
+			p.next()
+			nodes = append(nodes, mathError("}"))
+			continue
+		case mathCommand:
+			if tok.text == "right" || tok.text == "end" {
+				if p.depth > 0 {
+					return nodes
+				}
+				p.next()
+				nodes = append(nodes, mathError(`\`+tok.text))
+				continue
+			}
+		case mathSymbol:
+			if stop != "" && tok.text == stop {
+				return nodes
+			}
+		}
+		if node := p.parseScripted(); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// parseTable parses rows separated by \\ and cells separated by &.
+func (p *mathParser) parseTable() [][]string {
+	var rows [][]string
+	var row []string
+	for {
+		row = append(row, mathRow(p.parseRow("")))
+		switch p.peek().kind {
+		case mathAmp:
+			p.next()
+			continue
+		case mathNewline:
+			p.next()
+			rows = append(rows, row)
+			row = nil
+			continue
+		}
+		break
+	}
+	// 末尾多余的 \\ 会留下一个空行
+	if !(len(rows) > 0 && len(row) == 1 && row[0] == "<mrow></mrow>") {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// parseScripted parses one atom followed by any ^, _ and ' scripts.
+func (p *mathParser) parseScripted() string {
+	var base string
+	limits := limitsNone
+	switch p.peek().kind {
+	case mathSup, mathSub:
+		base = "<mrow></mrow>"
+	default:
+		base, limits = p.parseAtom(p.next())
+	}
+
+	var sub, sup, primes string
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == mathSup && sup == "":
+			p.next()
+			sup = p.parseArg()
+		case tok.kind == mathSub && sub == "":
+			p.next()
+			sub = p.parseArg()
+		case tok.kind == mathSymbol && tok.text == "'":
+			p.next()
+			primes += "′"
+		case tok.kind == mathCommand && tok.text == "limits":
+			p.next()
+			limits = limitsAlways
+		case tok.kind == mathCommand && tok.text == "nolimits":
+			p.next()
+			limits = limitsNone
+		default:
+			if primes != "" {
+				prime := "<mo>" + primes + "</mo>"
+				if sup != "" {
+					sup = "<mrow>" + prime + sup + "</mrow>"
+				} else {
+					sup = prime
+				}
+			}
+			return mathScripts(base, sub, sup, limits == limitsAlways || (limits == limitsDisplay && p.display))
+		}
+	}
+}
+
+// parseArg parses a command or script argument: a braced group or a single token.
+// Like TeX, \frac12 takes one digit per argument.
+func (p *mathParser) parseArg() string {
+	tok := p.peek()
+	switch tok.kind {
+	case mathEOF, mathClose, mathAmp, mathNewline:
+		return "<mrow></mrow>"
+	case mathNumber:
+		p.pos = tok.start + 1
+		return "<mn>" + p.styled(tok.text[:1]) + "</mn>"
+	}
+	node, _ := p.parseAtom(p.next())
+	return node
+}
+
+// parseGroup parses the contents of a braced group after its opening brace.
+func (p *mathParser) parseGroup() string {
+	p.depth++
+	nodes := p.parseRow("")
+	p.depth--
+	if p.peek().kind == mathClose {
+		p.next()
+	}
+	return mathRow(nodes)
+}
+
+// rawGroup reads a braced argument verbatim, used for \text and environment names.
+func (p *mathParser) rawGroup() string {
+	if p.peek().kind != mathOpen {
+		return ""
+	}
+	p.next()
+	start := p.pos
+	level := 1
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			level++
+		case '}':
+			level--
+			if level == 0 {
+				text := p.src[start:p.pos]
+				p.pos++
+				return text
+			}
+		}
+		p.pos++
+	}
+	text := p.src[start:]
+	p.pos = len(p.src)
+	return text
+}
+
+func (p *mathParser) parseAtom(tok mathToken) (string, mathLimits) {
+	switch tok.kind {
+	case mathNumber:
+		return "<mn>" + p.styled(tok.text) + "</mn>", limitsNone
+	case mathLetter:
+		if p.variant == "normal" {
+			return `<mi mathvariant="normal">` + html.EscapeString(tok.text) + "</mi>", limitsNone
+		}
+		return "<mi>" + p.styled(tok.text) + "</mi>", limitsNone
+	case mathOpen:
+		return p.parseGroup(), limitsNone
+	case mathTilde:
+		return mathSpace("0.3333em"), limitsNone
+	case mathSymbol:
+		return mathOperator(tok.text), limitsNone
+	case mathCommand:
+		return p.command(tok.text)
+	}
+	return "", limitsNone
+}
+
+func (p *mathParser) command(name string) (string, mathLimits) {
+	if v, ok := mathIdentifiers[name]; ok {
+		return "<mi>" + v + "</mi>", limitsNone
+	}
+	if v, ok := mathUprightIdentifiers[name]; ok {
+		return `<mi mathvariant="normal">` + v + "</mi>", limitsNone
+	}
+	if v, ok := mathOperators[name]; ok {
+		return "<mo>" + html.EscapeString(v) + "</mo>", limitsNone
+	}
+	if op, ok := mathBigOperators[name]; ok {
+		if op.limits {
+			return `<mo largeop="true" movablelimits="true">` + op.char + "</mo>", limitsDisplay
+		}
+		return `<mo largeop="true">` + op.char + "</mo>", limitsNone
+	}
+	if limits, ok := mathFunctions[name]; ok {
+		node := "<mi>" + name + "</mi>"
+		if limits {
+			return node, limitsDisplay
+		}
+		return node, limitsNone
+	}
+	if width, ok := mathSpaces[name]; ok {
+		return mathSpace(width), limitsNone
+	}
+	if variant, ok := mathVariants[name]; ok {
+		saved := p.variant
+		p.variant = variant
+		node := p.parseArg()
+		p.variant = saved
+		return node, limitsNone
+	}
+	if accent, ok := mathAccents[name]; ok {
+		base := p.parseArg()
+		switch {
+		case accent.under && accent.brace:
+			return "<munder>" + base + `<mo stretchy="true">` + accent.char + "</mo></munder>", limitsAlways
+		case accent.under:
+			return `<munder accentunder="true">` + base + `<mo stretchy="true">` + accent.char + "</mo></munder>", limitsNone
+		case accent.brace:
+			return "<mover>" + base + `<mo stretchy="true">` + accent.char + "</mo></mover>", limitsAlways
+		}
+		stretchy := "false"
+		if accent.stretchy {
+			stretchy = "true"
+		}
+		return `<mover accent="true">` + base + `<mo stretchy="` + stretchy + `">` + accent.char + "</mo></mover>", limitsNone
+	}
+	if size, ok := mathDelimiterSizes[name]; ok {
+		delim := p.delimiter()
+		if delim == "" {
+			return "", limitsNone
+		}
+		return `<mo minsize="` + size + `" maxsize="` + size + `">` + delim + "</mo>", limitsNone
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		node := "<mfrac>" + p.parseArg() + p.parseArg() + "</mfrac>"
+		return mathStyle(name, node), limitsNone
+	case "binom", "dbinom", "tbinom":
+		node := `<mrow><mo>(</mo><mfrac linethickness="0">` + p.parseArg() + p.parseArg() + "</mfrac><mo>)</mo></mrow>"
+		return mathStyle(name, node), limitsNone
+	case "sqrt":
+		if tok := p.peek(); tok.kind == mathSymbol && tok.text == "[" {
+			p.next()
+			p.depth++
+			index := mathRow(p.parseRow("]"))
+			p.depth--
+			if tok := p.peek(); tok.kind == mathSymbol && tok.text == "]" {
+				p.next()
+			}
+			return "<mroot>" + p.parseArg() + index + "</mroot>", limitsNone
+		}
+		return "<msqrt>" + p.parseArg() + "</msqrt>", limitsNone
+	case "text", "textrm", "textnormal", "textup", "mbox", "hbox":
+		return "<mtext>" + html.EscapeString(p.rawGroup()) + "</mtext>", limitsNone
+	case "textit", "emph":
+		return `<mtext mathvariant="italic">` + html.EscapeString(p.rawGroup()) + "</mtext>", limitsNone
+	case "textbf":
+		return `<mtext mathvariant="bold">` + html.EscapeString(p.rawGroup()) + "</mtext>", limitsNone
+	case "operatorname":
+		limits := limitsNone
+		if tok := p.peek(); tok.kind == mathSymbol && tok.text == "*" {
+			p.next()
+			limits = limitsDisplay
+		}
+		return "<mi>" + html.EscapeString(strings.TrimSpace(p.rawGroup())) + "</mi>", limits
+	case "overset", "stackrel":
+		over := p.parseArg()
+		return "<mover>" + p.parseArg() + over + "</mover>", limitsNone
+	case "underset":
+		under := p.parseArg()
+		return "<munder>" + p.parseArg() + under + "</munder>", limitsNone
+	case "not":
+		node, _ := p.parseAtom(p.next())
+		if negated, ok := mathNegations[node]; ok {
+			return "<mo>" + negated + "</mo>", limitsNone
+		}
+		if strings.HasPrefix(node, "<mo") {
+			return strings.Replace(node, "</mo>", "̸</mo>", 1), limitsNone
+		}
+		return node, limitsNone
+	case "pmod":
+		return "<mrow><mo>(</mo><mi>mod</mi>" + mathSpace("0.3333em") + p.parseArg() + "<mo>)</mo></mrow>", limitsNone
+	case "bmod", "mod":
+		return "<mo>mod</mo>", limitsNone
+	case "left":
+		return p.parseFenced(), limitsNone
+	case "begin":
+		return p.parseEnvironment(strings.TrimSpace(p.rawGroup())), limitsNone
+	case "displaystyle", "textstyle", "scriptstyle", "limits", "nolimits", "nonumber", "notag":
+		return "", limitsNone
+	}
+	return mathError(`\` + name), limitsNone
+}
+
+// parseFenced parses \left<delim> ... \right<delim> after \left.
+func (p *mathParser) parseFenced() string {
+	open := p.delimiter()
+	p.depth++
+	body := p.parseRow("")
+	p.depth--
+	close := ""
+	if tok := p.peek(); tok.kind == mathCommand && tok.text == "right" {
+		p.next()
+		close = p.delimiter()
+	}
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if open != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + open + "</mo>")
+	}
+	b.WriteString(strings.Join(body, ""))
+	if close != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + close + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return b.String()
+}
+
+// delimiter reads the delimiter after \left, \right or \big; "." means none.
+func (p *mathParser) delimiter() string {
+	tok := p.next()
+	switch tok.kind {
+	case mathSymbol:
+		if tok.text == "." {
+			return ""
+		}
+		return html.EscapeString(tok.text)
+	case mathCommand:
+		if v, ok := mathDelimiters[tok.text]; ok {
+			return v
+		}
+		if v, ok := mathOperators[tok.text]; ok {
+			return html.EscapeString(v)
+		}
+	}
+	return ""
+}
+
+// parseEnvironment parses \begin{name} ... \end{name}.
+func (p *mathParser) parseEnvironment(name string) string {
+	env, ok := mathEnvironments[strings.TrimSuffix(name, "*")]
+	if !ok {
+		env, ok = mathEnvironments[name]
+	}
+	if !ok {
+		p.skipEnvironment(name)
+		return mathError(`\begin{` + name + `}`)
+	}
+
+	align := env.align
+	if name == "array" {
+		// 列格式如 "c|l"，竖线等修饰忽略
+		align = strings.Map(func(r rune) rune {
+			if r == 'l' || r == 'c' || r == 'r' {
+				return r
+			}
+			return -1
+		}, p.rawGroup())
+	}
+
+	p.depth++
+	rows := p.parseTable()
+	p.depth--
+	if tok := p.peek(); tok.kind == mathCommand && tok.text == "end" {
+		p.next()
+		p.rawGroup()
+	}
+
+	table := mathTable(rows, align, env.display)
+	if env.open == "" && env.close == "" {
+		return table
+	}
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if env.open != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + env.open + "</mo>")
+	}
+	b.WriteString(table)
+	if env.close != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + env.close + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return b.String()
+}
+
+func (p *mathParser) skipEnvironment(name string) {
+	end := `\end{` + name + `}`
+	if i := strings.Index(p.src[p.pos:], end); i >= 0 {
+		p.pos += i + len(end)
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+// styled maps letters and digits to Unicode math alphanumerics for the
+// current font command (\mathbb, \mathbf, ...), which MathML Core renders
+// more reliably than the mathvariant attribute.
+func (p *mathParser) styled(s string) string {
+	if p.variant == "" || p.variant == "normal" {
+		return html.EscapeString(s)
+	}
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(mathAlphanumeric(p.variant, r))
+	}
+	return html.EscapeString(b.String())
+}
+
+func mathAlphanumeric(variant string, r rune) rune {
+	if special, ok := mathLetterExceptions[variant][r]; ok {
+		return special
+	}
+	base, ok := mathAlphabets[variant]
+	if !ok {
+		return r
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return base.upper + (r - 'A')
+	case r >= 'a' && r <= 'z' && base.lower != 0:
+		return base.lower + (r - 'a')
+	case r >= '0' && r <= '9' && base.digit != 0:
+		return base.digit + (r - '0')
+	}
+	return r
+}
+
+func mathRow(nodes []string) string {
+	switch len(nodes) {
+	case 0:
+		return "<mrow></mrow>"
+	case 1:
+		return nodes[0]
+	}
+	return "<mrow>" + strings.Join(nodes, "") + "</mrow>"
+}
+
+func mathScripts(base, sub, sup string, under bool) string {
+	switch {
+	case sub == "" && sup == "":
+		return base
+	case under && sub != "" && sup != "":
+		return "<munderover>" + base + sub + sup + "</munderover>"
+	case under && sub != "":
+		return "<munder>" + base + sub + "</munder>"
+	case under:
+		return "<mover>" + base + sup + "</mover>"
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>"
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>"
+	}
+	return "<msup>" + base + sup + "</msup>"
+}
+
+// mathTable renders rows as <mtable>; align holds one l/c/r letter per column.
+func mathTable(rows [][]string, align string, display bool) string {
+	var b strings.Builder
+	b.WriteString("<mtable")
+	if display {
+		b.WriteString(` displaystyle="true"`)
+	}
+	b.WriteByte('>')
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for i, cell := range row {
+			b.WriteString("<mtd")
+			if align != "" {
+				switch align[i%len(align)] {
+				case 'l':
+					b.WriteString(` columnalign="left" style="text-align: left"`)
+				case 'r':
+					b.WriteString(` columnalign="right" style="text-align: right"`)
+				}
+			}
+			b.WriteByte('>')
+			b.WriteString(cell)
+			b.WriteString("</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+	return b.String()
+}
+
+func mathStyle(name, node string) string {
+	switch name[0] {
+	case 'd':
+		return `<mstyle displaystyle="true">` + node + "</mstyle>"
+	case 't':
+		return `<mstyle displaystyle="false">` + node + "</mstyle>"
+	}
+	return node
+}
+
+func mathOperator(s string) string {
+	switch s {
+	case "-":
+		s = "−"
+	case "*":
+		s = "∗"
+	case "'":
+		s = "′"
+	}
+	return "<mo>" + html.EscapeString(s) + "</mo>"
+}
+
+func mathSpace(width string) string {
+	return `<mspace width="` + width + `"></mspace>`
+}
+
+func mathError(text string) string {
+	return "<merror><mtext>" + html.EscapeString(text) + "</mtext></merror>"
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"ell": "ℓ", "hbar": "ℏ", "imath": "ı", "jmath": "ȷ", "wp": "℘",
+}
+
+var mathUprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "emptyset": "∅", "varnothing": "∅", "partial": "∂", "nabla": "∇",
+	"aleph": "ℵ", "Re": "ℜ", "Im": "ℑ", "top": "⊤", "bot": "⊥", "angle": "∠",
+	"triangle": "△", "prime": "′",
+}
+
+var mathOperators = map[string]string{
+	"times": "×", "cdot": "⋅", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗", "star": "⋆",
+	"circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖", "otimes": "⊗", "odot": "⊙",
+	"cup": "∪", "cap": "∩", "setminus": "∖", "wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨",
+	"neg": "¬", "lnot": "¬",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "ll": "≪", "gg": "≫",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝",
+	"prec": "≺", "succ": "≻", "preceq": "⪯", "succeq": "⪰", "doteq": "≐",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃", "subseteq": "⊆",
+	"supseteq": "⊇", "perp": "⊥", "parallel": "∥", "mid": "∣", "nmid": "∤",
+	"forall": "∀", "exists": "∃", "nexists": "∄",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"mapsto": "↦", "longrightarrow": "⟶", "longleftarrow": "⟵", "uparrow": "↑", "downarrow": "↓",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"colon": ":", "vert": "|", "Vert": "‖", "|": "‖", "lvert": "|", "rvert": "|",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"{": "{", "}": "}", "lbrace": "{", "rbrace": "}", "$": "$", "%": "%", "&": "&", "#": "#", "_": "_",
+}
+
+var mathNegations = map[string]string{
+	"<mo>=</mo>": "≠", "<mo>∈</mo>": "∉", "<mo>≡</mo>": "≢", "<mo>∣</mo>": "∤",
+	"<mo>&lt;</mo>": "≮", "<mo>&gt;</mo>": "≯", "<mo>⊂</mo>": "⊄", "<mo>⊆</mo>": "⊈",
+}
+
+var mathDelimiters = map[string]string{
+	"{": "{", "}": "}", "lbrace": "{", "rbrace": "}", "|": "‖", "vert": "|", "Vert": "‖",
+	"lvert": "|", "rvert": "|", "lVert": "‖", "rVert": "‖",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+}
+
+var mathDelimiterSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "bigm": "1.2em",
+	"Big": "1.8em", "Bigl": "1.8em", "Bigr": "1.8em", "Bigm": "1.8em",
+	"bigg": "2.4em", "biggl": "2.4em", "biggr": "2.4em", "biggm": "2.4em",
+	"Bigg": "3em", "Biggl": "3em", "Biggr": "3em", "Biggm": "3em",
+}
+
+var mathBigOperators = map[string]struct {
+	char   string
+	limits bool
+}{
+	"sum": {"∑", true}, "prod": {"∏", true}, "coprod": {"∐", true},
+	"bigcup": {"⋃", true}, "bigcap": {"⋂", true}, "bigvee": {"⋁", true}, "bigwedge": {"⋀", true},
+	"bigoplus": {"⨁", true}, "bigotimes": {"⨂", true},
+	"int": {"∫", false}, "iint": {"∬", false}, "iiint": {"∭", false}, "oint": {"∮", false},
+}
+
+// 值表示在行间公式中下标是否放在正下方（如 \lim、\max）
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false, "tanh": false,
+	"log": false, "ln": false, "lg": false, "exp": false, "arg": false, "deg": false,
+	"dim": false, "ker": false, "hom": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true, "sup": true,
+	"inf": true, "det": true, "gcd": true, "Pr": true, "argmax": true, "argmin": true,
+}
+
+var mathSpaces = map[string]string{
+	",": "0.1667em", "thinspace": "0.1667em", ":": "0.2222em", ">": "0.2222em",
+	"medspace": "0.2222em", ";": "0.2778em", "thickspace": "0.2778em", "!": "-0.1667em",
+	" ": "0.3333em", "enspace": "0.5em", "quad": "1em", "qquad": "2em",
+}
+
+var mathVariants = map[string]string{
+	"mathrm": "normal", "mathup": "normal", "mathbf": "bold", "boldsymbol": "bold-italic",
+	"bm": "bold-italic", "mathit": "italic", "mathbb": "double-struck", "mathcal": "script",
+	"mathscr": "script", "mathfrak": "fraktur", "mathsf": "sans-serif", "mathtt": "monospace",
+}
+
+var mathAccents = map[string]struct {
+	char     string
+	stretchy bool
+	under    bool
+	brace    bool
+}{
+	"hat": {char: "^"}, "widehat": {char: "^", stretchy: true}, "check": {char: "ˇ"},
+	"tilde": {char: "~"}, "widetilde": {char: "~", stretchy: true}, "bar": {char: "¯"},
+	"overline": {char: "‾", stretchy: true}, "vec": {char: "→"}, "dot": {char: "˙"},
+	"ddot": {char: "¨"}, "acute": {char: "´"}, "grave": {char: "`"}, "breve": {char: "˘"},
+	"overrightarrow": {char: "→", stretchy: true}, "overleftarrow": {char: "←", stretchy: true},
+	"underline": {char: "_", stretchy: true, under: true},
+	"overbrace": {char: "⏞", brace: true}, "underbrace": {char: "⏟", under: true, brace: true},
+}
+
+var mathEnvironments = map[string]struct {
+	open, close string
+	align       string
+	display     bool
+}{
+	"matrix":      {},
+	"smallmatrix": {},
+	"pmatrix":     {open: "(", close: ")"},
+	"bmatrix":     {open: "[", close: "]"},
+	"Bmatrix":     {open: "{", close: "}"},
+	"vmatrix":     {open: "|", close: "|"},
+	"Vmatrix":     {open: "‖", close: "‖"},
+	"cases":       {open: "{", align: "l"},
+	"array":       {},
+	"aligned":     {align: "rl", display: true},
+	"align":       {align: "rl", display: true},
+	"alignat":     {align: "rl", display: true},
+	"split":       {align: "rl", display: true},
+	"eqnarray":    {align: "rcl", display: true},
+	"gathered":    {display: true},
+	"gather":      {display: true},
+	"equation":    {display: true},
+}
+
+var mathAlphabets = map[string]struct{ upper, lower, digit rune }{
+	"bold":          {0x1D400, 0x1D41A, 0x1D7CE},
+	"italic":        {0x1D434, 0x1D44E, 0},
+	"bold-italic":   {0x1D468, 0x1D482, 0x1D7CE},
+	"double-struck": {0x1D538, 0x1D552, 0x1D7D8},
+	"script":        {0x1D49C, 0x1D4B6, 0},
+	"fraktur":       {0x1D504, 0x1D51E, 0},
+	"sans-serif":    {0x1D5A0, 0x1D5BA, 0x1D7E2},
+	"monospace":     {0x1D670, 0x1D68A, 0x1D7F6},
+}
+
+// Unicode 数学字母区中有空位，这些字母使用早先已编码的字符
+var mathLetterExceptions = map[string]map[rune]rune{
+	"italic":        {'h': 'ℎ'},
+	"double-struck": {'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'},
+	"script": {'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ',
+		'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ'},
+	"fraktur": {'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ'},
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"strings"
+	"testing"
+)
+
+// mathBody strips the <math> wrapper and the TeX annotation from the output.
+func mathBody(t *testing.T, out string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(out, "<semantics>")
+	if !ok {
+		t.Fatalf("no <semantics> in %s", out)
+	}
+	body, _, ok := strings.Cut(rest, `<annotation encoding="application/x-tex">`)
+	if !ok {
+		t.Fatalf("no TeX annotation in %s", out)
+	}
+	return body
+}
+
+func TestLatexToMathML(t *testing.T) {
+	tests := []struct {
+		name string
+		tex  string
+		want string
+	}{
+		{"identifier", `x`, `<mi>x</mi>`},
+		{"number", `12.5`, `<mn>12.5</mn>`},
+		{"superscript", `x^2`, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{"sub and superscript", `x_i^2`, `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`},
+		{"fraction", `\frac{a}{b}`, `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+		{"fraction missing argument", `\frac{a}`, `<mfrac><mi>a</mi><mrow></mrow></mfrac>`},
+		{"square root", `\sqrt{x}`, `<msqrt><mi>x</mi></msqrt>`},
+		{"nth root", `\sqrt[3]{x}`, `<mroot><mi>x</mi><mn>3</mn></mroot>`},
+		{"greek letters", `\alpha + \beta`, `<mrow><mi>α</mi><mo>+</mo><mi>β</mi></mrow>`},
+		{"operator is escaped", `a < b`, `<mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>`},
+		{"negated relation", `\neq`, `<mo>≠</mo>`},
+		{"function name", `\sin x`, `<mrow><mi>sin</mi><mi>x</mi></mrow>`},
+		{"double-struck letter", `\mathbb{R}`, `<mi>ℝ</mi>`},
+		{"accent", `\hat{x}`, `<mover accent="true"><mi>x</mi><mo stretchy="false">^</mo></mover>`},
+		{
+			"big operator with limits",
+			`\sum_{i=1}^{n} i`,
+			`<mrow><msubsup><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi></mrow>`,
+		},
+		{
+			"fences",
+			`\left( x \right)`,
+			`<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi><mo fence="true" stretchy="true">)</mo></mrow>`,
+		},
+		{
+			"matrix environment",
+			`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`,
+			`<mrow><mo fence="true" stretchy="true">(</mo><mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable><mo fence="true" stretchy="true">)</mo></mrow>`,
+		},
+		{"unknown command", `\unknown`, `<merror><mtext>\unknown</mtext></merror>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mathBody(t, latexToMathML(tt.tex, false)); got != tt.want {
+				t.Errorf("latexToMathML(%q)\n got %s\nwant %s", tt.tex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatexToMathMLWrapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		tex     string
+		display bool
+		prefix  string
+		suffix  string
+	}{
+		{
+			"inline",
+			`x`,
+			false,
+			`<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics>`,
+			`<annotation encoding="application/x-tex">x</annotation></semantics></math>`,
+		},
+		{
+			"display",
+			`x`,
+			true,
+			`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics>`,
+			`<annotation encoding="application/x-tex">x</annotation></semantics></math>`,
+		},
+		{
+			"annotation is trimmed and escaped",
+			" a & b < c ",
+			false,
+			`<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics>`,
+			`<annotation encoding="application/x-tex">a &amp; b &lt; c</annotation></semantics></math>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := latexToMathML(tt.tex, tt.display)
+			if !strings.HasPrefix(got, tt.prefix) || !strings.HasSuffix(got, tt.suffix) {
+				t.Errorf("latexToMathML(%q, %v) = %s", tt.tex, tt.display, got)
+			}
+		})
+	}
+}
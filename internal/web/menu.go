@@ -0,0 +1,227 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"myblog/internal/blog"
+	"myblog/internal/i18n"
+)
+
+// editableMenus lists the menus shown in the settings form, in order.
+var editableMenus = []struct {
+	Name  string
+	Label string
+}{
+	{Name: blog.MenuHeader, Label: "顶部导航"},
+	{Name: blog.MenuFooter, Label: "页脚链接"},
+}
+
+// 菜单最多两层：顶级项与其下拉子项
+const maxMenuDepth = 1
+
+// navItem is a menu item ready for templates, with Href already resolved
+// against SiteBaseURL.
+type navItem struct {
+	Name     string
+	Href     string
+	External bool
+	Children []navItem
+}
+
+// menu resolves a named menu for templates in the given locale. Published
+// pages flagged for the navigation are appended to the header menu.
+func (s *Server) menu(locale string, profile blog.SiteProfile, name string) []navItem {
+	items := navItems(s.Config.SiteBaseURL, locale, profile.Menu(name))
+	if name == blog.MenuHeader {
+		for _, page := range s.navPages() {
+			items = append(items, navItem{Name: page.Title, Href: s.Config.SiteBaseURL + "/" + page.Path})
+		}
+	}
+	return items
+}
+
+func navItems(base, locale string, items []blog.MenuItem) []navItem {
+	out := make([]navItem, 0, len(items))
+	for _, item := range items {
+		nav := navItem{Name: item.Name, Href: item.URL, External: item.IsExternal()}
+		if item.Key != "" {
+			nav.Name = i18n.T(locale, item.Key)
+		}
+		if strings.HasPrefix(item.URL, "/") {
+			nav.Href = base + item.URL
+		}
+		nav.Children = navItems(base, locale, item.Children)
+		out = append(out, nav)
+	}
+	return out
+}
+
+// menuRow is one flattened row of a list editor in the settings form.
+type menuRow struct {
+	Prefix string
+	Nested bool
+	Name   string
+	URL    string
+	Depth  int
+}
+
+// menuEditor is the state of one list editor: a named menu, or the social
+// links, which use the same rows without nesting.
+type menuEditor struct {
+	Prefix string
+	Label  string
+	Nested bool
+	Rows   []menuRow
+}
+
+// Blank is the empty row the editor clones when adding a link.
+func (e menuEditor) Blank() menuRow {
+	return menuRow{Prefix: e.Prefix, Nested: e.Nested}
+}
+
+func (e menuEditor) withRows(rows []menuRow) menuEditor {
+	e.Rows = make([]menuRow, 0, len(rows))
+	for _, row := range rows {
+		row.Prefix, row.Nested = e.Prefix, e.Nested
+		e.Rows = append(e.Rows, row)
+	}
+	return e
+}
+
+func flattenMenu(items []blog.MenuItem, depth int) []menuRow {
+	var rows []menuRow
+	for _, item := range items {
+		rows = append(rows, menuRow{Name: item.Name, URL: item.URL, Depth: depth})
+		rows = append(rows, flattenMenu(item.Children, depth+1)...)
+	}
+	return rows
+}
+
+// menuEditors builds the list editors from a saved profile.
+func menuEditors(profile blog.SiteProfile) []menuEditor {
+	editors := make([]menuEditor, 0, len(editableMenus)+1)
+	for _, m := range editableMenus {
+		editor := menuEditor{Prefix: "menu_" + m.Name, Label: m.Label, Nested: true}
+		editors = append(editors, editor.withRows(flattenMenu(profile.Menu(m.Name), 0)))
+	}
+	social := make([]menuRow, 0, len(profile.SocialLinks))
+	for _, link := range profile.SocialLinks {
+		social = append(social, menuRow{Name: link.Name, URL: link.URL})
+	}
+	editors = append(editors, menuEditor{Prefix: "social", Label: "社交链接"}.withRows(social))
+	return editors
+}
+
+// submittedMenuEditors rebuilds the list editors from a posted form, so a
+// validation error keeps what the admin typed.
+func submittedMenuEditors(r *http.Request) []menuEditor {
+	editors := menuEditors(blog.SiteProfile{})
+	for i := range editors {
+		editors[i] = editors[i].withRows(parseMenuRows(r, editors[i].Prefix))
+	}
+	return editors
+}
+
+// parseMenuRows reads the parallel name/url/depth fields posted by a list
+// editor. Rows left completely empty are dropped.
+func parseMenuRows(r *http.Request, prefix string) []menuRow {
+	names := r.Form[prefix+"_name"]
+	urls := r.Form[prefix+"_url"]
+	depths := r.Form[prefix+"_depth"]
+	var rows []menuRow
+	for i := range names {
+		row := menuRow{Name: strings.TrimSpace(names[i])}
+		if i < len(urls) {
+			row.URL = strings.TrimSpace(urls[i])
+		}
+		if i < len(depths) {
+			row.Depth, _ = strconv.Atoi(depths[i])
+		}
+		if row.Name == "" && row.URL == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// buildMenu validates the rows and nests each row under the closest
+// preceding row one level up.
+func buildMenu(label string, rows []menuRow) ([]blog.MenuItem, error) {
+	var items []blog.MenuItem
+	for i, row := range rows {
+		if row.Name == "" || row.URL == "" {
+			return nil, fmt.Errorf("%s第 %d 项需要同时填写名称和链接", label, i+1)
+		}
+		if err := validateMenuURL(row.URL); err != nil {
+			return nil, fmt.Errorf("%s「%s」：%v", label, row.Name, err)
+		}
+		depth := min(max(row.Depth, 0), maxMenuDepth)
+		siblings := &items
+		for d := 0; d < depth; d++ {
+			if len(*siblings) == 0 {
+				return nil, fmt.Errorf("%s「%s」是子项，但前面没有上级菜单", label, row.Name)
+			}
+			siblings = &(*siblings)[len(*siblings)-1].Children
+		}
+		*siblings = append(*siblings, blog.MenuItem{Name: row.Name, URL: row.URL})
+	}
+	if items == nil {
+		items = []blog.MenuItem{}
+	}
+	return items, nil
+}
+
+// validateMenuURL accepts root-relative site paths, in-page anchors and
+// absolute http(s) or mailto: links.
+func validateMenuURL(raw string) error {
+	// 浏览器会去掉链接里的制表符和换行，"/\t/evil.com" 实际指向 //evil.com
+	if strings.ContainsAny(raw, "\t\r\n") {
+		return fmt.Errorf("链接 %q 不能包含制表符或换行", raw)
+	}
+	if strings.HasPrefix(raw, "#") {
+		return nil
+	}
+	// 浏览器把 "\" 当作 "/"，"/\evil.com" 与 "//evil.com" 一样会跳到其它站点
+	if strings.HasPrefix(raw, "//") || strings.HasPrefix(raw, "/\\") {
+		return fmt.Errorf("链接 %q 缺少协议，请写成 https://…", raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("链接 %q 格式不正确", raw)
+	}
+	switch {
+	case strings.HasPrefix(raw, "/"):
+		return nil
+	case u.Scheme == "http" || u.Scheme == "https":
+		if u.Host == "" {
+			return fmt.Errorf("链接 %q 缺少域名", raw)
+		}
+		return nil
+	case u.Scheme == "mailto":
+		if u.Opaque == "" {
+			return fmt.Errorf("链接 %q 缺少邮箱地址", raw)
+		}
+		return nil
+	}
+	return fmt.Errorf("链接 %q 需要以 / 开头的站内路径，或 http(s):// 与 mailto: 开头的外部链接", raw)
+}
+
+// parseSocialRows validates the rows of the social link editor.
+func parseSocialRows(rows []menuRow) ([]blog.SocialLink, error) {
+	var links []blog.SocialLink
+	for i, row := range rows {
+		if row.Name == "" || row.URL == "" {
+			return nil, fmt.Errorf("社交链接第 %d 项需要同时填写名称和链接", i+1)
+		}
+		if err := validateMenuURL(row.URL); err != nil {
+			return nil, fmt.Errorf("社交链接「%s」：%v", row.Name, err)
+		}
+		links = append(links, blog.SocialLink{Name: row.Name, URL: row.URL})
+	}
+	return links, nil
+}
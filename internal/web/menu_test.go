@@ -0,0 +1,31 @@
+package web
+
+import "testing"
+
+func TestValidateMenuURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"/posts", true},
+		{"/#about", true},
+		{"#top", true},
+		{"/", true},
+		{"https://example.com/a", true},
+		{"mailto:me@example.com", true},
+		{"//evil.com", false},
+		{`/\evil.com`, false},
+		{`/\/evil.com`, false},
+		{"/\t/evil.com", false},
+		{"/posts\n", false},
+		{"https://", false},
+		{"mailto:", false},
+		{"javascript:alert(1)", false},
+		{"posts", false},
+	}
+	for _, tt := range tests {
+		if err := validateMenuURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("validateMenuURL(%q) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
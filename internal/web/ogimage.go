@@ -0,0 +1,421 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+
+	"myblog/internal/blog"
+)
+
+// ogFontData is a subset of Noto Sans CJK SC Bold covering ASCII, Latin-1,
+// CJK punctuation, kana and the GB2312 hanzi. See fonts/OFL.txt.
+//
+//go:embed fonts/NotoSansCJKsc-Bold-Subset.ttf
+var ogFontData []byte
+
+const (
+	ogWidth  = 1200
+	ogHeight = 630
+	// ogImageVersion is part of the cache key; bump it when the layout changes
+	ogImageVersion = "1"
+	ogPadding      = 80
+	ogAvatarSize   = 96
+	ogTitleLines   = 3
+)
+
+var (
+	ogBackground = color.RGBA{0x0a, 0x0a, 0x0a, 0xff}
+	ogInk        = color.RGBA{0xfa, 0xfa, 0xfa, 0xff}
+	ogMuted      = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+	ogAccent     = color.RGBA{0xe6, 0x39, 0x46, 0xff}
+)
+
+var (
+	ogFontOnce sync.Once
+	ogFont     *opentype.Font
+	ogFontErr  error
+)
+
+func loadOGFont() (*opentype.Font, error) {
+	ogFontOnce.Do(func() {
+		ogFont, ogFontErr = opentype.Parse(ogFontData)
+	})
+	return ogFont, ogFontErr
+}
+
+// ogImagePath is the stable URL path of a post's generated share image.
+func ogImagePath(slug string) string {
+	return "/og/" + url.PathEscape(slug) + ".png"
+}
+
+// ogCard holds everything drawn on a share image.
+type ogCard struct {
+	Title     string
+	SiteTitle string
+	Host      string
+	Tags      []string
+	// AvatarPath is the local file of the site avatar, if it has one
+	AvatarPath string
+}
+
+func (s *Server) ogCardFor(post blog.Post) ogCard {
+	profile := s.SiteStore.Get()
+	card := ogCard{Title: post.Title, SiteTitle: profile.Title, Tags: post.Tags}
+	if u, err := url.Parse(s.Config.SiteBaseURL); err == nil {
+		card.Host = u.Host
+	}
+	// 只使用本地上传的头像，生成图片时不请求外部地址
+	avatar := strings.TrimPrefix(profile.Avatar, normalizeBaseURL(s.Config.SiteBaseURL))
+	if strings.HasPrefix(avatar, "/uploads/") {
+		card.AvatarPath = filepath.FromSlash(strings.TrimPrefix(avatar, "/"))
+	}
+	return card
+}
+
+// hash identifies the rendered image: the card contents plus the avatar
+// file's size and modification time.
+func (c ogCard) hash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%s\x00%s\x00%s\x00%s\x00%s\x00%s", ogImageVersion, c.Title, c.SiteTitle, c.Host, strings.Join(c.Tags, "\x1f"), c.AvatarPath)
+	if c.AvatarPath != "" {
+		if info, err := os.Stat(c.AvatarPath); err == nil {
+			fmt.Fprintf(h, "\x00%d\x00%d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ogCacheDir holds rendered share images named {slug}-{hash}.png.
+func (s *Server) ogCacheDir() string {
+	return filepath.Join(s.Config.DataDir, "cache", "og")
+}
+
+// ogCacheName is the cache file name of a post's share image. The slug is
+// escaped so it always stays one path element.
+func ogCacheName(slug, hash string) string {
+	return url.PathEscape(slug) + "-" + hash + ".png"
+}
+
+// pruneOGCache deletes the cached images of slug other than keep, left over
+// from earlier titles, tags or avatars, and images cached under the old
+// hash-only names.
+func (s *Server) pruneOGCache(slug, keep string) {
+	entries, err := os.ReadDir(s.ogCacheDir())
+	if err != nil {
+		return
+	}
+	prefix := url.PathEscape(slug) + "-"
+	for _, entry := range entries {
+		name := entry.Name()
+		base, ok := strings.CutSuffix(name, ".png")
+		if !ok || name == keep {
+			continue
+		}
+		// 哈希固定为 16 位十六进制，按长度切开，避免 slug "a" 误删 "a-b" 的图片
+		if len(base) < 16 || !isHex(base[len(base)-16:]) {
+			continue
+		}
+		if owner := base[:len(base)-16]; owner != prefix && owner != "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.ogCacheDir(), name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove old share image %s: %v", name, err)
+		}
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// OGImage serves /og/{slug}.png, the 1200x630 share image of a post. Images
+// are rendered once per content hash and kept in the data directory.
+func (s *Server) OGImage(w http.ResponseWriter, r *http.Request) {
+	slug, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/og/"), ".png")
+	if !ok || slug == "" {
+		http.NotFound(w, r)
+		return
+	}
+	post, ok := s.Store.GetBySlug(slug)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	card := s.ogCardFor(post)
+	hash := card.hash()
+	name := ogCacheName(slug, hash)
+	cachePath := filepath.Join(s.ogCacheDir(), name)
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		data, err = renderOGImage(card)
+		if err != nil {
+			log.Printf("Failed to render share image for %s: %v", slug, err)
+			http.Error(w, "Failed to render image", http.StatusInternalServerError)
+			return
+		}
+		if err := writeFileAtomic(cachePath, data); err != nil {
+			log.Printf("Failed to cache share image for %s: %v", slug, err)
+		} else {
+			s.pruneOGCache(slug, name)
+		}
+	}
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeContent(w, r, "og.png", time.Time{}, bytes.NewReader(data))
+}
+
+// writeFileAtomic writes through a temporary file so concurrent readers never
+// see a partial image.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// renderOGImage draws the card: avatar and site title at the top, the post
+// title in the middle and the tags along the bottom.
+func renderOGImage(card ogCard) ([]byte, error) {
+	f, err := loadOGFont()
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, ogWidth, ogHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 16, ogHeight), image.NewUniform(ogAccent), image.Point{}, draw.Src)
+
+	// 头像与站点名
+	headerX := ogPadding
+	if card.AvatarPath != "" {
+		if avatar, err := loadAvatar(card.AvatarPath, ogAvatarSize); err == nil {
+			rect := image.Rect(ogPadding, ogPadding-20, ogPadding+ogAvatarSize, ogPadding-20+ogAvatarSize)
+			draw.DrawMask(img, rect, avatar, image.Point{}, circleMask(ogAvatarSize), image.Point{}, draw.Over)
+			headerX += ogAvatarSize + 24
+		} else {
+			log.Printf("Failed to load avatar %s for share image: %v", card.AvatarPath, err)
+		}
+	}
+	siteFace, err := newOGFace(f, 36)
+	if err != nil {
+		return nil, err
+	}
+	drawText(img, siteFace, ogInk, headerX, ogPadding+40, card.SiteTitle)
+
+	// 标题最多三行，放不下时先缩小字号，再截断加省略号
+	maxWidth := fixed.I(ogWidth - 2*ogPadding)
+	var titleFace font.Face
+	var lines []string
+	var size float64
+	for _, size = range []float64{72, 60, 52} {
+		if titleFace, err = newOGFace(f, size); err != nil {
+			return nil, err
+		}
+		if lines = wrapText(titleFace, card.Title, maxWidth); len(lines) <= ogTitleLines {
+			break
+		}
+	}
+	if len(lines) > ogTitleLines {
+		lines = lines[:ogTitleLines]
+		lines[ogTitleLines-1] = truncateText(titleFace, lines[ogTitleLines-1], maxWidth)
+	}
+	lineHeight := int(size * 1.3)
+	y := 250 + int(size) - (len(lines)-1)*lineHeight/2
+	for _, line := range lines {
+		drawText(img, titleFace, ogInk, ogPadding, y, line)
+		y += lineHeight
+	}
+
+	// 底部：标签与站点域名
+	tagFace, err := newOGFace(f, 28)
+	if err != nil {
+		return nil, err
+	}
+	bottom := ogHeight - ogPadding + 10
+	hostWidth := 0
+	if card.Host != "" {
+		hostWidth = font.MeasureString(tagFace, card.Host).Ceil()
+		drawText(img, tagFace, ogMuted, ogWidth-ogPadding-hostWidth, bottom, card.Host)
+	}
+	x := ogPadding
+	for _, tag := range card.Tags {
+		label := "#" + tag
+		width := font.MeasureString(tagFace, label).Ceil()
+		if x+width > ogWidth-ogPadding-hostWidth-40 {
+			break
+		}
+		drawText(img, tagFace, ogAccent, x, bottom, label)
+		x += width + 28
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newOGFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(text)
+}
+
+// isCJK reports whether a line may break before and after r.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+// noLineStart lists punctuation that must not begin a line.
+const noLineStart = "，。、；：！？）」』》〉】”’,.;:!?)]}"
+
+// wrapText breaks text into lines no wider than width, between words for
+// Latin text and between characters for CJK text.
+func wrapText(face font.Face, text string, width fixed.Int26_6) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.Join(strings.Fields(text), " ") {
+		switch {
+		case r == ' ':
+			flush()
+			tokens = append(tokens, " ")
+		case isCJK(r):
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+
+	var lines []string
+	var line strings.Builder
+	for _, token := range tokens {
+		candidate := line.String() + token
+		fits := font.MeasureString(face, candidate) <= width
+		if fits || line.Len() == 0 || strings.Contains(noLineStart, token) {
+			line.WriteString(token)
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+		line.Reset()
+		if token != " " {
+			line.WriteString(token)
+		}
+	}
+	if line.Len() > 0 {
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+	}
+	// 单个过长的单词按字符拆开
+	var out []string
+	for _, l := range lines {
+		for font.MeasureString(face, l) > width {
+			runes := []rune(l)
+			n := len(runes) - 1
+			for n > 1 && font.MeasureString(face, string(runes[:n])) > width {
+				n--
+			}
+			out = append(out, string(runes[:n]))
+			l = string(runes[n:])
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// truncateText shortens a line until it fits with a trailing ellipsis.
+func truncateText(face font.Face, line string, width fixed.Int26_6) string {
+	runes := []rune(strings.TrimSpace(line))
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimRight(string(runes), " ") + "…"
+}
+
+// loadAvatar decodes an image file and scales its centre square to size.
+func loadAvatar(path string, size int) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, xdraw.Src, nil)
+	return dst, nil
+}
+
+// circleMask is an anti-aliased disc used to crop the avatar.
+func circleMask(size int) image.Image {
+	mask := image.NewAlpha(image.Rect(0, 0, size, size))
+	r := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-r, float64(y)+0.5-r
+			d := r - math.Hypot(dx, dy)
+			switch {
+			case d >= 1:
+				mask.SetAlpha(x, y, color.Alpha{0xff})
+			case d > 0:
+				mask.SetAlpha(x, y, color.Alpha{uint8(d * 0xff)})
+			}
+		}
+	}
+	return mask
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"myblog/internal/config"
+)
+
+func TestPruneOGCache(t *testing.T) {
+	s := &Server{Config: &config.Config{DataDir: t.TempDir()}}
+	dir := s.ogCacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{
+		ogCacheName("a", "0123456789abcdef"),
+		ogCacheName("a", "fedcba9876543210"),
+		// 另一篇文章的 slug 以 "a-" 开头，不能被误删
+		ogCacheName("a-b", "0123456789abcdef"),
+		// 旧版本只按哈希命名的文件
+		"1111111111111111.png",
+		"notes.txt",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.pruneOGCache("a", ogCacheName("a", "fedcba9876543210"))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	want := []string{"a-b-0123456789abcdef.png", "a-fedcba9876543210.png", "notes.txt"}
+	sort.Strings(got)
+	if len(got) != len(want) {
+		t.Fatalf("left %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("left %v, want %v", got, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package web
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"myblog/internal/blog"
+)
+
+// pageTemplate is a layout an admin can pick for a standalone page.
+type pageTemplate struct {
+	File  string
+	Label string
+}
+
+// pageTemplates 的第一项是默认模板
+var pageTemplates = []pageTemplate{
+	{File: "page.html", Label: "标准（标题 + 正文）"},
+	{File: "page_wide.html", Label: "宽版（无标题区，正文全宽）"},
+}
+
+// reservedPagePrefixes are first path segments already used by other routes.
+var reservedPagePrefixes = map[string]bool{
+	"admin": true, "archive": true, "categories": true, "feed.xml": true,
+	"feeds": true, "og": true, "page": true, "posts": true, "robots.txt": true, "search": true,
+	"series": true, "sitemap.xml": true, "sitemaps": true, "static": true, "tags": true, "uploads": true,
+}
+
+var pagePathPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*(/[a-z0-9][a-z0-9_-]*)*$`)
+
+// validatePagePath checks a page path such as "about" or "projects/tools".
+func validatePagePath(path string) error {
+	if !pagePathPattern.MatchString(path) {
+		return errors.New("路径只能包含小写字母、数字、- 和 _，多级路径用 / 分隔")
+	}
+	first, _, _ := strings.Cut(path, "/")
+	if reservedPagePrefixes[first] {
+		return errors.New("路径 /" + first + " 已被站点使用")
+	}
+	return nil
+}
+
+func pageTemplateFile(name string) string {
+	for _, t := range pageTemplates {
+		if t.File == name {
+			return t.File
+		}
+	}
+	return pageTemplates[0].File
+}
+
+// navPages returns published pages flagged for the header navigation.
+func (s *Server) navPages() []blog.Page {
+	var pages []blog.Page
+	for _, page := range s.Pages.ListPages() {
+		if page.ShowInNav && !page.IsDraft {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// publishedPages returns every page that is served publicly.
+func (s *Server) publishedPages() []blog.Page {
+	var pages []blog.Page
+	for _, page := range s.Pages.ListPages() {
+		if !page.IsDraft {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// PageDetail serves a published standalone page at /{path}.
+func (s *Server) PageDetail(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	page, ok := s.Pages.GetPage(path)
+	if path == "" || !ok || page.IsDraft {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := s.baseData(r)
+	data["Page"] = page
+	content := page.ContentHTML
+	if content == "" && page.Content != "" {
+		// 尚未预渲染，临时渲染一次
+		content = renderPost(blog.Post{Content: page.Content}, s.Store.GetBySlug, s.shortcodeTemplates()).HTML
+	}
+	content = s.rewriteHTMLAssetURLs(content)
+	data["PageHTML"] = template.HTML(content)
+	data["HasMermaid"] = strings.Contains(content, mermaidOpenTag)
+	data["Title"] = page.Title + " - " + data["Title"].(string)
+	if page.Summary != "" {
+		data["Description"] = page.Summary
+	}
+	data["CurrentPath"] = r.URL.Path
+	addStructuredData(data, s.breadcrumbList(data["Locale"].(string), breadcrumb{page.Title, r.URL.EscapedPath()}))
+	s.render(w, pageTemplateFile(page.Template), data)
+}
+
+func (s *Server) AdminPages(w http.ResponseWriter, r *http.Request) {
+	data := s.baseData(r)
+	data["PageTitle"] = "页面管理"
+	data["Pages"] = s.Pages.ListPages()
+	s.render(w, "admin_pages.html", data)
+}
+
+func (s *Server) AdminPageNew(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.renderPageForm(w, r, "新建页面", "", blog.Page{}, "/admin/pages/new")
+	case http.MethodPost:
+		page := parsePageForm(r)
+		if err := validatePagePath(page.Path); err != nil {
+			s.renderPageForm(w, r, "新建页面", err.Error(), page, "/admin/pages/new")
+			return
+		}
+		if err := s.Pages.CreatePage(page); err != nil {
+			s.renderPageForm(w, r, "新建页面", err.Error(), page, "/admin/pages/new")
+			return
+		}
+		http.Redirect(w, r, "/admin/pages", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) AdminPageEdit(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	action := "/admin/pages/edit?path=" + path
+	switch r.Method {
+	case http.MethodGet:
+		page, ok := s.Pages.GetPage(path)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.renderPageForm(w, r, "编辑页面", "", page, action)
+	case http.MethodPost:
+		page := parsePageForm(r)
+		if err := validatePagePath(page.Path); err != nil {
+			s.renderPageForm(w, r, "编辑页面", err.Error(), page, action)
+			return
+		}
+		if err := s.Pages.UpdatePage(path, page); err != nil {
+			s.renderPageForm(w, r, "编辑页面", err.Error(), page, action)
+			return
+		}
+		http.Redirect(w, r, "/admin/pages", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) AdminPageDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_ = s.Pages.DeletePage(r.FormValue("path"))
+	http.Redirect(w, r, "/admin/pages", http.StatusSeeOther)
+}
+
+func (s *Server) renderPageForm(w http.ResponseWriter, r *http.Request, pageTitle, msg string, page blog.Page, action string) {
+	data := s.baseData(r)
+	data["PageTitle"] = pageTitle
+	data["Error"] = msg
+	data["Page"] = page
+	data["Action"] = action
+	data["PageTemplates"] = pageTemplates
+	s.render(w, "admin_page_form.html", data)
+}
+
+func parsePageForm(r *http.Request) blog.Page {
+	_ = r.ParseForm()
+	return blog.Page{
+		Path:      strings.Trim(strings.ToLower(strings.TrimSpace(r.FormValue("path"))), "/"),
+		Title:     strings.TrimSpace(r.FormValue("title")),
+		Summary:   strings.TrimSpace(r.FormValue("summary")),
+		Content:   strings.TrimSpace(r.FormValue("content")),
+		Template:  pageTemplateFile(r.FormValue("template")),
+		ShowInNav: r.FormValue("show_in_nav") == "on",
+		NavOrder:  parseOrder(r.FormValue("nav_order")),
+		IsDraft:   r.FormValue("is_draft") == "on",
+	}
+}
+
+// invalidatingPageStore drops the render cache after every successful page
+// write, since every page shows the navigation.
+type invalidatingPageStore struct {
+	blog.PageStore
+	cache *renderCache
+}
+
+func (s *invalidatingPageStore) CreatePage(page blog.Page) error {
+	if err := s.PageStore.CreatePage(page); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingPageStore) UpdatePage(path string, page blog.Page) error {
+	if err := s.PageStore.UpdatePage(path, page); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingPageStore) SavePageRendered(path, html string) error {
+	if err := s.PageStore.SavePageRendered(path, html); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingPageStore) DeletePage(path string) error {
+	if err := s.PageStore.DeletePage(path); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
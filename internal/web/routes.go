@@ -5,26 +5,34 @@ import "net/http"
 func (s *Server) PublicRoutes() http.Handler {
 	mux := http.NewServeMux()
 
-	// 静态资源（CSS/图片等）
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	// 静态资源（CSS/图片等），优先取当前主题的文件；带指纹的地址长期缓存
+	mux.Handle("/static/", s.staticHandler())
 	mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
 
-	// 页面
-	mux.HandleFunc("/", s.Index)
-	mux.HandleFunc("/page/", s.Index)
-	mux.HandleFunc("/posts", s.PostsList)
-	mux.HandleFunc("/posts/", s.PostDetail)
-	mux.HandleFunc("/archive", s.ArchivePage)
+	// 页面（渲染结果按内容版本缓存）
+	mux.HandleFunc("/", s.cached(s.Index))
+	mux.HandleFunc("/page/", s.cached(s.Index))
+	mux.HandleFunc("/posts", s.cached(s.PostsList))
+	mux.HandleFunc("/posts/", s.cached(s.PostDetail))
+	mux.HandleFunc("/tags/", s.cached(s.TagPosts))
+	mux.HandleFunc("/categories/", s.cached(s.CategoryPosts))
+	mux.HandleFunc("/series/", s.cached(s.SeriesPage))
+	mux.HandleFunc("/archive", s.cached(s.ArchivePage))
 	mux.HandleFunc("/search", s.SearchPage)
-	mux.HandleFunc("/sitemap.xml", s.Sitemap)
+	mux.HandleFunc("/sitemap.xml", s.cached(s.Sitemap))
+	mux.HandleFunc("/sitemaps/", s.cached(s.SitemapSection))
+	mux.HandleFunc("/robots.txt", s.cached(s.Robots))
+	mux.HandleFunc("/feed.xml", s.cached(s.Feed))
+	mux.HandleFunc("/feeds/", s.cached(s.LanguageFeed))
+	mux.HandleFunc("/og/", s.OGImage)
 
-	return mux
+	return compress(mux)
 }
 
 func (s *Server) AdminRoutes() http.Handler {
 	mux := http.NewServeMux()
-	// 静态资源（CSS/图片等）
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	// 静态资源（CSS/图片等），优先取当前主题的文件；带指纹的地址长期缓存
+	mux.Handle("/static/", s.staticHandler())
 	mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
 	mux.HandleFunc("/admin/login", s.AdminLogin)
 	mux.HandleFunc("/admin/logout", s.AdminLogout)
@@ -32,7 +40,18 @@ func (s *Server) AdminRoutes() http.Handler {
 	mux.HandleFunc("/admin/posts/new", s.AdminPostNew)
 	mux.HandleFunc("/admin/posts/edit", s.AdminPostEdit)
 	mux.HandleFunc("/admin/posts/delete", s.AdminPostDelete)
+	mux.HandleFunc("/admin/posts/rerender", s.AdminRerender)
+	mux.HandleFunc("/admin/pages", s.AdminPages)
+	mux.HandleFunc("/admin/pages/new", s.AdminPageNew)
+	mux.HandleFunc("/admin/pages/edit", s.AdminPageEdit)
+	mux.HandleFunc("/admin/pages/delete", s.AdminPageDelete)
+	mux.HandleFunc("/admin/series", s.AdminSeries)
+	mux.HandleFunc("/admin/series/new", s.AdminSeriesNew)
+	mux.HandleFunc("/admin/series/edit", s.AdminSeriesEdit)
+	mux.HandleFunc("/admin/series/delete", s.AdminSeriesDelete)
+	mux.HandleFunc("/admin/links", s.AdminLinks)
+	mux.HandleFunc("/admin/orphans", s.AdminOrphans)
 	mux.HandleFunc("/admin/settings", s.AdminSettings)
 	mux.HandleFunc("/admin/upload", s.AdminUpload)
-	return adminAuth(mux)
+	return compress(adminAuth(mux))
 }
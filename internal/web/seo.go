@@ -0,0 +1,184 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"myblog/internal/blog"
+	"myblog/internal/i18n"
+)
+
+// jsonLD is one schema.org object, written into the page head as an
+// application/ld+json script.
+type jsonLD map[string]any
+
+const schemaContext = "https://schema.org"
+
+// breadcrumb is one step of a page's trail below the home page. Path is a
+// site path such as /posts.
+type breadcrumb struct {
+	Name string
+	Path string
+}
+
+// canonicalURL is the absolute URL of the requested page, without the query.
+func (s *Server) canonicalURL(r *http.Request) string {
+	return s.siteURL(r.URL.EscapedPath())
+}
+
+// siteURL turns a site path into an absolute URL under SiteBaseURL.
+func (s *Server) siteURL(path string) string {
+	if path == "" {
+		path = "/"
+	}
+	return s.Config.SiteBaseURL + path
+}
+
+// escapedPath joins path segments, escaping each one so that tag and slug
+// names with spaces or CJK characters stay valid in URLs.
+func escapedPath(segments ...string) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(segment))
+	}
+	return b.String()
+}
+
+// ogLocale converts a BCP 47 tag to the language_TERRITORY form of og:locale.
+func ogLocale(tag string) string {
+	return strings.ReplaceAll(tag, "-", "_")
+}
+
+// twitterHandle finds the @handle among the social links, for twitter:site.
+func twitterHandle(profile blog.SiteProfile) string {
+	for _, link := range profile.SocialLinks {
+		u, err := url.Parse(strings.TrimSpace(link.URL))
+		if err != nil {
+			continue
+		}
+		host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+		if host != "twitter.com" && host != "x.com" {
+			continue
+		}
+		if name, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/"); name != "" {
+			return "@" + name
+		}
+	}
+	return ""
+}
+
+// addStructuredData appends JSON-LD objects to the page data.
+func addStructuredData(data map[string]any, items ...jsonLD) {
+	existing, _ := data["StructuredData"].([]jsonLD)
+	data["StructuredData"] = append(existing, items...)
+}
+
+// author describes the site owner as a schema.org Person.
+func (s *Server) author(profile blog.SiteProfile) jsonLD {
+	person := jsonLD{
+		"@type": "Person",
+		"name":  profile.Title,
+		"url":   s.siteURL("/"),
+	}
+	if profile.Avatar != "" {
+		person["image"] = s.assetURL(profile.Avatar)
+	}
+	if profile.Positioning != "" {
+		person["jobTitle"] = profile.Positioning
+	}
+	var sameAs []string
+	for _, link := range profile.SocialLinks {
+		if strings.HasPrefix(link.URL, "http://") || strings.HasPrefix(link.URL, "https://") {
+			sameAs = append(sameAs, link.URL)
+		}
+	}
+	if len(sameAs) > 0 {
+		person["sameAs"] = sameAs
+	}
+	return person
+}
+
+// homeStructuredData describes the site and its owner, with a SearchAction
+// pointing at the search page.
+func (s *Server) homeStructuredData(profile blog.SiteProfile, locale string) []jsonLD {
+	person := s.author(profile)
+	person["@context"] = schemaContext
+	if profile.Tagline != "" {
+		person["description"] = profile.Tagline
+	}
+	website := jsonLD{
+		"@context":   schemaContext,
+		"@type":      "WebSite",
+		"name":       profile.Title,
+		"url":        s.siteURL("/"),
+		"inLanguage": locale,
+		"author":     s.author(profile),
+		"potentialAction": jsonLD{
+			"@type":       "SearchAction",
+			"target":      s.siteURL("/search") + "?query={search_term_string}",
+			"query-input": "required name=search_term_string",
+		},
+	}
+	if profile.Intro != "" {
+		website["description"] = profile.Intro
+	}
+	return []jsonLD{website, person}
+}
+
+// postStructuredData describes a post as a BlogPosting.
+func (s *Server) postStructuredData(post blog.Post, profile blog.SiteProfile, canonical, image string) jsonLD {
+	posting := jsonLD{
+		"@context":         schemaContext,
+		"@type":            "BlogPosting",
+		"headline":         post.Title,
+		"url":              canonical,
+		"mainEntityOfPage": jsonLD{"@type": "WebPage", "@id": canonical},
+		"inLanguage":       s.postLang(post),
+		"author":           s.author(profile),
+		"publisher":        s.author(profile),
+	}
+	if post.Summary != "" {
+		posting["description"] = post.Summary
+	}
+	if !post.CreatedAt.IsZero() {
+		posting["datePublished"] = post.CreatedAt.Format(time.RFC3339)
+	}
+	if !post.UpdatedAt.IsZero() {
+		posting["dateModified"] = post.UpdatedAt.Format(time.RFC3339)
+	}
+	if image != "" {
+		posting["image"] = s.assetURL(image)
+	}
+	if post.Category != "" {
+		posting["articleSection"] = post.Category
+	}
+	if len(post.Tags) > 0 {
+		posting["keywords"] = strings.Join(post.Tags, ", ")
+	}
+	if post.WordCount > 0 {
+		posting["wordCount"] = post.WordCount
+	}
+	return posting
+}
+
+// breadcrumbList builds a BreadcrumbList that starts at the home page.
+func (s *Server) breadcrumbList(locale string, crumbs ...breadcrumb) jsonLD {
+	crumbs = append([]breadcrumb{{Name: i18n.T(locale, "nav.breadcrumb_home"), Path: "/"}}, crumbs...)
+	items := make([]jsonLD, 0, len(crumbs))
+	for i, crumb := range crumbs {
+		items = append(items, jsonLD{
+			"@type":    "ListItem",
+			"position": i + 1,
+			"name":     crumb.Name,
+			"item":     s.siteURL(crumb.Path),
+		})
+	}
+	return jsonLD{
+		"@context":        schemaContext,
+		"@type":           "BreadcrumbList",
+		"itemListElement": items,
+	}
+}
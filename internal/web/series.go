@@ -0,0 +1,247 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"myblog/internal/blog"
+)
+
+// SeriesNav locates a post within its series for the navigation box.
+type SeriesNav struct {
+	Series  blog.Series
+	Posts   []blog.Post
+	Current int
+	Prev    *blog.Post
+	Next    *blog.Post
+}
+
+// Position is the 1-based position of the current post.
+func (n *SeriesNav) Position() int {
+	return n.Current + 1
+}
+
+// seriesNav returns nil when the post is not part of a published series.
+func (s *Server) seriesNav(post blog.Post) *SeriesNav {
+	if post.Series == "" {
+		return nil
+	}
+	series, ok := s.Series.GetSeries(post.Series)
+	if !ok {
+		return nil
+	}
+	posts := s.Store.ListBySeries(series.Slug)
+	for i, p := range posts {
+		if p.Slug != post.Slug {
+			continue
+		}
+		nav := &SeriesNav{Series: series, Posts: posts, Current: i}
+		if i > 0 {
+			nav.Prev = &posts[i-1]
+		}
+		if i+1 < len(posts) {
+			nav.Next = &posts[i+1]
+		}
+		return nav
+	}
+	// 草稿预览时文章不在已发布列表中，只显示系列信息
+	return &SeriesNav{Series: series, Posts: posts, Current: -1}
+}
+
+// SeriesPage lists the published posts of a series in reading order.
+func (s *Server) SeriesPage(w http.ResponseWriter, r *http.Request) {
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/series/"), "/")
+	series, ok := s.Series.GetSeries(slug)
+	if slug == "" || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	posts := s.Store.ListBySeries(slug)
+
+	data := s.baseData(r)
+	data["Series"] = series
+	data["Posts"] = posts
+	data["Title"] = series.Name + " - " + data["Title"].(string)
+	if series.Description != "" {
+		data["Description"] = series.Description
+	}
+	data["CurrentPath"] = r.URL.Path
+	addStructuredData(data, s.breadcrumbList(data["Locale"].(string), breadcrumb{series.Name, r.URL.EscapedPath()}))
+	s.render(w, "series.html", data)
+}
+
+// seriesWithPosts returns every series that has at least one published post.
+func (s *Server) seriesWithPosts() []blog.Series {
+	var list []blog.Series
+	for _, series := range s.Series.ListSeries() {
+		if len(s.Store.ListBySeries(series.Slug)) > 0 {
+			list = append(list, series)
+		}
+	}
+	return list
+}
+
+func (s *Server) AdminSeries(w http.ResponseWriter, r *http.Request) {
+	type seriesRow struct {
+		blog.Series
+		Count int
+	}
+	var rows []seriesRow
+	for _, series := range s.Series.ListSeries() {
+		rows = append(rows, seriesRow{Series: series, Count: len(s.Store.ListBySeries(series.Slug))})
+	}
+	data := s.baseData(r)
+	data["PageTitle"] = "系列管理"
+	data["SeriesRows"] = rows
+	s.render(w, "admin_series.html", data)
+}
+
+func (s *Server) AdminSeriesNew(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.renderSeriesForm(w, r, "新建系列", "", blog.Series{}, "/admin/series/new")
+	case http.MethodPost:
+		series := parseSeriesForm(r)
+		if series.Slug == "" {
+			series.Slug = slugify(series.Name)
+		}
+		if series.Name == "" || series.Slug == "" {
+			s.renderSeriesForm(w, r, "新建系列", "需要填写名称，且 slug 或名称包含英文/数字。", series, "/admin/series/new")
+			return
+		}
+		if err := s.Series.CreateSeries(series); err != nil {
+			s.renderSeriesForm(w, r, "新建系列", err.Error(), series, "/admin/series/new")
+			return
+		}
+		http.Redirect(w, r, "/admin/series", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) AdminSeriesEdit(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get("slug")
+	action := "/admin/series/edit?slug=" + url.QueryEscape(slug)
+	switch r.Method {
+	case http.MethodGet:
+		series, ok := s.Series.GetSeries(slug)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.renderSeriesForm(w, r, "编辑系列", "", series, action)
+	case http.MethodPost:
+		series := parseSeriesForm(r)
+		if series.Slug == "" {
+			series.Slug = slugify(series.Name)
+		}
+		if series.Name == "" || series.Slug == "" {
+			s.renderSeriesForm(w, r, "编辑系列", "需要填写名称，且 slug 或名称包含英文/数字。", series, action)
+			return
+		}
+		if err := s.Series.UpdateSeries(slug, series); err != nil {
+			s.renderSeriesForm(w, r, "编辑系列", err.Error(), series, action)
+			return
+		}
+		http.Redirect(w, r, "/admin/series", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) AdminSeriesDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_ = s.Series.DeleteSeries(r.FormValue("slug"))
+	http.Redirect(w, r, "/admin/series", http.StatusSeeOther)
+}
+
+func (s *Server) renderSeriesForm(w http.ResponseWriter, r *http.Request, pageTitle, msg string, series blog.Series, action string) {
+	data := s.baseData(r)
+	data["PageTitle"] = pageTitle
+	data["Error"] = msg
+	data["Series"] = series
+	data["Action"] = action
+	if series.Slug != "" {
+		data["Members"] = s.Store.ListBySeries(series.Slug)
+	}
+	s.render(w, "admin_series_form.html", data)
+}
+
+func parseSeriesForm(r *http.Request) blog.Series {
+	_ = r.ParseForm()
+	return blog.Series{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Slug:        strings.TrimSpace(r.FormValue("slug")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+}
+
+// parseOrder reads a non-negative position number; anything else counts as 0.
+func parseOrder(raw string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// invalidatingSeriesStore drops the render cache after every successful
+// series write, since post pages show the series box. Renaming or deleting a
+// series also changes its posts, so their copies in the related-posts index
+// are refreshed.
+type invalidatingSeriesStore struct {
+	blog.SeriesStore
+	posts blog.Store
+	// related is nil when the server runs without a related-posts index
+	related *blog.RelatedStore
+	cache   *renderCache
+}
+
+func (s *invalidatingSeriesStore) CreateSeries(series blog.Series) error {
+	if err := s.SeriesStore.CreateSeries(series); err != nil {
+		return err
+	}
+	s.cache.invalidate()
+	return nil
+}
+
+func (s *invalidatingSeriesStore) UpdateSeries(slug string, series blog.Series) error {
+	members := s.members(slug)
+	if err := s.SeriesStore.UpdateSeries(slug, series); err != nil {
+		return err
+	}
+	s.refresh(members)
+	return nil
+}
+
+func (s *invalidatingSeriesStore) DeleteSeries(slug string) error {
+	members := s.members(slug)
+	if err := s.SeriesStore.DeleteSeries(slug); err != nil {
+		return err
+	}
+	s.refresh(members)
+	return nil
+}
+
+// members lists the slugs of every post in a series, drafts included.
+func (s *invalidatingSeriesStore) members(slug string) []string {
+	var slugs []string
+	for _, post := range s.posts.List() {
+		if post.Series == slug {
+			slugs = append(slugs, post.Slug)
+		}
+	}
+	return slugs
+}
+
+func (s *invalidatingSeriesStore) refresh(members []string) {
+	if s.related != nil {
+		s.related.Refresh(members...)
+	}
+	s.cache.invalidate()
+}
@@ -1,7 +1,10 @@
 package web
 
 import (
-	"html/template"
+	"log"
+	"sync"
+	"time"
+
 	"myblog/internal/blog"
 	"myblog/internal/config"
 )
@@ -9,15 +12,173 @@ import (
 type Server struct {
 	Config        *config.Config
 	Store         blog.Store
+	Series        blog.SeriesStore
+	Pages         blog.PageStore
 	SiteStore     *blog.SiteStore
-	TemplateCache map[string]*template.Template
+	TemplateCache *TemplateCache
+
+	cache     *renderCache
+	rendering *blog.RenderingStore
+	// pageRendering renders standalone pages the same way
+	pageRendering *blog.RenderingPageStore
+	links         lastLinkReport
+	rerenders     backgroundRerender
+	graph         *linkGraph
+	themes        themeCache
+	hashes        assetHashes
 }
 
-func NewServer(cfg *config.Config, store blog.Store, siteStore *blog.SiteStore) *Server {
-	return &Server{
+func NewServer(cfg *config.Config, store blog.Store, series blog.SeriesStore, pages blog.PageStore, siteStore *blog.SiteStore) *Server {
+	assetsFromDisk.Store(cfg.Dev)
+
+	// 初始的 Last-Modified 取文章、系列、页面与站点设置中最晚的更新时间
+	lastModified := siteStore.UpdatedAt()
+	for _, post := range store.List() {
+		if post.UpdatedAt.After(lastModified) {
+			lastModified = post.UpdatedAt
+		}
+	}
+	for _, s := range series.ListSeries() {
+		if s.UpdatedAt.After(lastModified) {
+			lastModified = s.UpdatedAt
+		}
+	}
+	for _, page := range pages.ListPages() {
+		if page.UpdatedAt.After(lastModified) {
+			lastModified = page.UpdatedAt
+		}
+	}
+	cache := newRenderCache(lastModified)
+	srv := &Server{
 		Config:        cfg,
-		Store:         store,
 		SiteStore:     siteStore,
-		TemplateCache: make(map[string]*template.Template),
+		TemplateCache: &TemplateCache{},
+		cache:         cache,
+	}
+
+	// 文章与页面在保存时渲染一次，短代码使用当前主题的模板；升级后尚未渲染过或渲染器版本变了的在启动时补齐
+	render := srv.renderer(store)
+	rendering := blog.NewRenderingStore(store, render)
+	if n, err := rendering.RenderMissing(); err != nil {
+		log.Printf("Failed to render posts: %v", err)
+	} else if n > 0 {
+		log.Printf("Rendered %d post(s) with missing or outdated HTML", n)
+	}
+	pageRendering := blog.NewRenderingPageStore(pages, render)
+	if n, err := pageRendering.RenderMissing(); err != nil {
+		log.Printf("Failed to render pages: %v", err)
+	} else if n > 0 {
+		log.Printf("Rendered %d page(s) with missing or outdated HTML", n)
+	}
+
+	// 反向链接图随文章写入更新，启动时从已渲染的内容建立
+	graph := newLinkGraph(cfg.SiteBaseURL)
+	graph.rebuild(rendering.List())
+
+	// 写入文章后，引用它的文章与页面要重新解析 post 短代码
+	refs := &postRefStore{Store: &linkGraphStore{Store: rendering, graph: graph}, pages: pageRendering, render: render}
+	srv.Store = &invalidatingStore{Store: refs, cache: cache}
+	seriesStore := &invalidatingSeriesStore{SeriesStore: series, posts: srv.Store, cache: cache}
+	if related, ok := store.(*blog.RelatedStore); ok {
+		seriesStore.related = related
+	}
+	srv.Series = seriesStore
+	srv.Pages = &invalidatingPageStore{PageStore: pageRendering, cache: cache}
+	srv.rendering = rendering
+	srv.pageRendering = pageRendering
+	srv.graph = graph
+
+	// 切换主题后，已存储的 HTML 要用新主题的短代码模板重新渲染
+	var themeMu sync.Mutex
+	theme := srv.ActiveTheme().Manifest.Name
+	siteStore.OnUpdate(func(blog.SiteProfile) {
+		cache.invalidate()
+		themeMu.Lock()
+		defer themeMu.Unlock()
+		if name := srv.ActiveTheme().Manifest.Name; name != theme {
+			theme = name
+			srv.rerenderForTemplates()
+		}
+	})
+
+	if cfg.Dev {
+		// 开发模式下模板改动后自动重新解析
+		go srv.watchTemplates(templateWatchInterval)
+	}
+	return srv
+}
+
+// rerenderForTemplates re-renders stored HTML in the background after the
+// shortcode templates changed, with a theme switch or a template edit in dev
+// mode, so the request that switched the theme does not wait for it.
+func (s *Server) rerenderForTemplates() {
+	s.rerenders.start(func() {
+		start := time.Now()
+		if n, err := s.RerenderAll(); err != nil {
+			log.Printf("Failed to re-render posts after a template change: %v", err)
+		} else {
+			log.Printf("Re-rendered %d post(s) with the current shortcode templates in %s", n, time.Since(start).Round(time.Millisecond))
+		}
+	})
+}
+
+// backgroundRerender runs template re-renders one at a time. A change that
+// arrives while one is running queues a single further run, so the stored
+// HTML always ends up rendered with the latest templates.
+type backgroundRerender struct {
+	mu      sync.Mutex
+	running bool
+	pending bool
+}
+
+func (b *backgroundRerender) start(run func()) {
+	b.mu.Lock()
+	if b.running {
+		b.pending = true
+		b.mu.Unlock()
+		return
+	}
+	b.running = true
+	b.mu.Unlock()
+
+	go func() {
+		for {
+			run()
+			b.mu.Lock()
+			if !b.pending {
+				b.running = false
+				b.mu.Unlock()
+				return
+			}
+			b.pending = false
+			b.mu.Unlock()
+		}
+	}()
+}
+
+// RerenderAll re-renders the stored HTML of every post and page, e.g. after a
+// renderer change, and returns the number of posts.
+func (s *Server) RerenderAll() (int, error) {
+	n, err := s.rendering.RerenderAll()
+	if err == nil {
+		_, err = s.pageRendering.RerenderAll()
+	}
+	s.graph.rebuild(s.rendering.List())
+	s.cache.invalidate()
+	return n, err
+}
+
+// NewRenderer returns the function the server renders posts and pages with,
+// using the active theme's shortcode templates. cmd/rerender uses it to render
+// stored HTML without starting a server.
+func NewRenderer(cfg *config.Config, store blog.Store, siteStore *blog.SiteStore) blog.RenderFunc {
+	assetsFromDisk.Store(cfg.Dev)
+	srv := &Server{Config: cfg, SiteStore: siteStore, TemplateCache: &TemplateCache{}}
+	return srv.renderer(store)
+}
+
+func (s *Server) renderer(store blog.Store) blog.RenderFunc {
+	return func(post blog.Post) blog.Rendered {
+		return renderPost(post, store.GetBySlug, s.shortcodeTemplates())
 	}
 }
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackgroundRerenderQueuesOneRun(t *testing.T) {
+	var b backgroundRerender
+	var runs atomic.Int32
+	release := make(chan struct{})
+	done := make(chan struct{}, 10)
+	run := func() {
+		if runs.Add(1) == 1 {
+			<-release
+		}
+		done <- struct{}{}
+	}
+
+	b.start(run)
+	// 第一次运行期间的多次变化只补跑一次
+	for i := 0; i < 3; i++ {
+		b.start(run)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("run %d did not finish", i+1)
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("more than one queued run")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := runs.Load(); got != 2 {
+		t.Errorf("ran %d times, want 2", got)
+	}
+
+	// 空闲后再次触发会立即运行
+	b.start(run)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("run after idle did not start")
+	}
+}
@@ -0,0 +1,648 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"myblog/internal/blog"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// shortcodeDir holds one template per shortcode, relative to the template
+// directory of the default templates or a theme; the file name is the
+// shortcode name.
+const shortcodeDir = "shortcodes"
+
+// shortcodeInnerMarker stands in for .Inner while executing a paired shortcode,
+// so the template output can be split around the rendered children.
+const shortcodeInnerMarker = "<!--shortcode-inner-->"
+
+// postLookup resolves a slug to a post, normally Store.GetBySlug.
+type postLookup func(slug string) (blog.Post, bool)
+
+// ShortcodeWarning reports a shortcode in a post that cannot be rendered as written,
+// such as an unknown name or a reference to a missing or unpublished post.
+type ShortcodeWarning struct {
+	Post    string
+	Message string
+}
+
+// ShortcodeWarnings checks the shortcodes of every published post.
+func (s *Server) ShortcodeWarnings() []ShortcodeWarning {
+	var warnings []ShortcodeWarning
+	for _, post := range s.Store.ListPublished() {
+		state := &shortcodeState{lookup: s.Store.GetBySlug, templates: s.shortcodeTemplates()}
+		markdown.Parser().Parse(text.NewReader([]byte(post.Content)), newParseContext(state))
+		for _, msg := range state.warnings {
+			warnings = append(warnings, ShortcodeWarning{Post: post.Slug, Message: msg})
+		}
+	}
+	return warnings
+}
+
+// shortcodeTemplates parses the active theme's shortcode templates over the
+// default ones. They live in the template cache, so a theme switch or a
+// template reload in dev mode picks up the new files.
+func (s *Server) shortcodeTemplates() *template.Template {
+	key := s.ActiveTheme().Manifest.Name + "/" + shortcodeDir
+	if t, ok := s.TemplateCache.get(key); ok {
+		return t
+	}
+	t, err := template.ParseFS(s.templateFS(), shortcodeDir+"/*.html")
+	if err != nil {
+		log.Printf("Failed to load shortcode templates: %v", err)
+		t = template.New("")
+	}
+	s.TemplateCache.put(key, t)
+	return t
+}
+
+var (
+	defaultShortcodeOnce      sync.Once
+	defaultShortcodeTemplates *template.Template
+)
+
+// loadDefaultShortcodeTemplates parses the built-in shortcode templates once,
+// for documents rendered without a server, such as plain Markdown fields.
+func loadDefaultShortcodeTemplates() *template.Template {
+	defaultShortcodeOnce.Do(func() {
+		t, err := template.ParseFS(defaultAssets(defaultTemplateDir), shortcodeDir+"/*.html")
+		if err != nil {
+			log.Printf("Failed to load shortcode templates: %v", err)
+			t = template.New("")
+		}
+		defaultShortcodeTemplates = t
+	})
+	return defaultShortcodeTemplates
+}
+
+// shortcodeState is kept in the parser context of each document.
+type shortcodeState struct {
+	lookup postLookup
+	// templates holds the shortcode templates; nil means the built-in ones
+	templates *template.Template
+	warnings  []string
+}
+
+func (st *shortcodeState) template(name string) *template.Template {
+	templates := st.templates
+	if templates == nil {
+		templates = loadDefaultShortcodeTemplates()
+	}
+	return templates.Lookup(name + ".html")
+}
+
+var shortcodeStateKey = parser.NewContextKey()
+
+func (st *shortcodeState) warnf(format string, args ...any) {
+	st.warnings = append(st.warnings, fmt.Sprintf(format, args...))
+}
+
+// shortcodeCall is one parsed {{< name args >}} tag.
+type shortcodeCall struct {
+	Name   string
+	Args   []string
+	Named  map[string]string
+	Source string
+	Post   *blog.Post
+	// tmpl is the template the tag renders with, nil for unknown names
+	tmpl *template.Template
+}
+
+// shortcodeData is the template context: positional and named arguments,
+// the rendered inner content of paired shortcodes and, for the post
+// shortcode, the referenced post.
+type shortcodeData struct {
+	Name  string
+	Args  []string
+	Named map[string]string
+	Inner template.HTML
+	Post  *blog.Post
+	Block bool
+}
+
+// Get returns a positional (int) or named (string) argument, or "".
+func (d shortcodeData) Get(key any) string {
+	switch k := key.(type) {
+	case int:
+		if k >= 0 && k < len(d.Args) {
+			return d.Args[k]
+		}
+	case string:
+		return d.Named[k]
+	}
+	return ""
+}
+
+var shortcodeNamePattern = regexp.MustCompile(`^/?[A-Za-z][A-Za-z0-9_-]*$`)
+
+// parseShortcodeTag parses a tag at the start of b and returns its length.
+func parseShortcodeTag(b []byte) (call shortcodeCall, closing bool, n int, ok bool) {
+	if !bytes.HasPrefix(b, []byte("{{<")) {
+		return call, false, 0, false
+	}
+	end := bytes.Index(b, []byte(">}}"))
+	if end < 0 {
+		return call, false, 0, false
+	}
+	fields := splitShortcodeArgs(string(b[3:end]))
+	if len(fields) == 0 || !shortcodeNamePattern.MatchString(fields[0]) {
+		return call, false, 0, false
+	}
+	n = end + 3
+	call = shortcodeCall{Named: map[string]string{}, Source: string(b[:n])}
+	call.Name, closing = strings.CutPrefix(fields[0], "/")
+	for _, arg := range fields[1:] {
+		if key, value, found := strings.Cut(arg, "="); found && shortcodeNamePattern.MatchString(key) {
+			call.Named[key] = unquoteShortcodeArg(value)
+			continue
+		}
+		call.Args = append(call.Args, unquoteShortcodeArg(arg))
+	}
+	return call, closing, n, true
+}
+
+// splitShortcodeArgs splits on spaces outside of "double" or `back` quotes.
+func splitShortcodeArgs(s string) []string {
+	var fields []string
+	var cur strings.Builder
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '`'):
+			quote = r
+		case quote == 0 && (r == ' ' || r == '\t' || r == '\n'):
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func unquoteShortcodeArg(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if v, err := strconv.Unquote(s); err == nil {
+			return v
+		}
+	}
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// resolve checks the template exists and looks up the post a post shortcode
+// points to. Problems are recorded as warnings and rendered as broken links.
+func (c *shortcodeCall) resolve(pc parser.Context) {
+	state := shortcodeStateOf(pc)
+	c.tmpl = state.template(c.Name)
+	if c.tmpl == nil {
+		state.warnf("unknown shortcode %q", c.Name)
+		return
+	}
+	if c.Name != "post" {
+		return
+	}
+
+	slug := c.postSlug()
+	if slug == "" {
+		state.warnf("post shortcode without a slug")
+		return
+	}
+	if state.lookup == nil {
+		return
+	}
+	post, ok := state.lookup(slug)
+	switch {
+	case !ok:
+		state.warnf("post shortcode references missing post %q", slug)
+	case post.IsDraft:
+		state.warnf("post shortcode references unpublished post %q", slug)
+	default:
+		c.Post = &post
+	}
+}
+
+// postSlug is the slug a post shortcode points to: the slug argument or the
+// first positional one.
+func (c *shortcodeCall) postSlug() string {
+	if slug := c.Named["slug"]; slug != "" {
+		return slug
+	}
+	if len(c.Args) > 0 {
+		return c.Args[0]
+	}
+	return ""
+}
+
+// referencesPost reports whether Markdown content has a post shortcode
+// pointing to one of slugs.
+func referencesPost(content string, slugs map[string]bool) bool {
+	b := []byte(content)
+	for {
+		i := bytes.Index(b, []byte("{{<"))
+		if i < 0 {
+			return false
+		}
+		b = b[i:]
+		call, closing, n, ok := parseShortcodeTag(b)
+		if !ok {
+			b = b[3:]
+			continue
+		}
+		if !closing && call.Name == "post" && slugs[call.postSlug()] {
+			return true
+		}
+		b = b[n:]
+	}
+}
+
+// postRefStore re-renders the posts and pages whose post shortcodes point to
+// a post that was just written. Their stored HTML resolved the reference
+// when they were saved, so a target that is published, renamed or deleted
+// later would otherwise keep showing the old link or the broken marker.
+type postRefStore struct {
+	blog.Store
+	pages  blog.PageStore
+	render blog.RenderFunc
+}
+
+func (s *postRefStore) Create(post blog.Post) error {
+	if err := s.Store.Create(post); err != nil {
+		return err
+	}
+	s.rerenderReferrers(post.Slug)
+	return nil
+}
+
+func (s *postRefStore) Update(slug string, post blog.Post) error {
+	if err := s.Store.Update(slug, post); err != nil {
+		return err
+	}
+	s.rerenderReferrers(slug, post.Slug)
+	return nil
+}
+
+func (s *postRefStore) Delete(slug string) error {
+	if err := s.Store.Delete(slug); err != nil {
+		return err
+	}
+	s.rerenderReferrers(slug)
+	return nil
+}
+
+// rerenderReferrers renders again every post and page referencing one of
+// slugs. Failures are logged: the write itself already succeeded.
+func (s *postRefStore) rerenderReferrers(slugs ...string) {
+	targets := map[string]bool{}
+	for _, slug := range slugs {
+		if slug != "" {
+			targets[slug] = true
+		}
+	}
+	for _, post := range s.Store.List() {
+		if targets[post.Slug] || !referencesPost(post.Content, targets) {
+			continue
+		}
+		if err := s.Store.SaveRendered(post.Slug, s.render(post)); err != nil {
+			log.Printf("Failed to re-render post %s: %v", post.Slug, err)
+		}
+	}
+	for _, page := range s.pages.ListPages() {
+		if !referencesPost(page.Content, targets) {
+			continue
+		}
+		if err := s.pages.SavePageRendered(page.Path, s.render(blog.Post{Content: page.Content}).HTML); err != nil {
+			log.Printf("Failed to re-render page %s: %v", page.Path, err)
+		}
+	}
+}
+
+// shortcodes adds {{< name args >}} tags rendered from the templates in
+// shortcodeDir. A tag alone on a line is a block; if a matching
+// {{< /name >}} line follows, the lines between are parsed as Markdown
+// and passed to the template as .Inner. Tags inside a paragraph are inline
+// and pair with a closing tag on the same line.
+var shortcodes = &shortcodeExtension{}
+
+type shortcodeExtension struct{}
+
+func (e *shortcodeExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&shortcodeBlockParser{}, 80)),
+		parser.WithInlineParsers(util.Prioritized(&shortcodeInlineParser{}, 80)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&shortcodeRenderer{}, 100),
+	))
+}
+
+var (
+	kindShortcodeBlock  = ast.NewNodeKind("ShortcodeBlock")
+	kindShortcodeInline = ast.NewNodeKind("ShortcodeInline")
+)
+
+type shortcodeBlock struct {
+	ast.BaseBlock
+	call     shortcodeCall
+	paired   bool
+	unclosed bool
+	suffix   string
+}
+
+func (n *shortcodeBlock) Kind() ast.NodeKind { return kindShortcodeBlock }
+
+func (n *shortcodeBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.call.Name}, nil)
+}
+
+type shortcodeInline struct {
+	ast.BaseInline
+	call shortcodeCall
+	// inner is the Markdown between an inline opening and closing tag,
+	// parsed as its own document
+	inner    []byte
+	innerDoc ast.Node
+	// broken tags, such as a closing tag without an opening one, are shown
+	// as written
+	broken bool
+}
+
+func (n *shortcodeInline) Kind() ast.NodeKind { return kindShortcodeInline }
+
+func (n *shortcodeInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.call.Name}, nil)
+}
+
+type shortcodeBlockParser struct{}
+
+func (p *shortcodeBlockParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (p *shortcodeBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	tag := util.TrimRightSpace(line[pos:])
+	call, closing, n, ok := parseShortcodeTag(tag)
+	if !ok || closing || n != len(tag) {
+		return nil, parser.NoChildren
+	}
+	call.resolve(pc)
+
+	node := &shortcodeBlock{call: call}
+	node.paired = hasClosingShortcode(reader.Source()[segment.Stop:], call.Name)
+	if !node.paired {
+		node.unclosed = checkUnclosed(&call, pc)
+	}
+	advanceLine(reader, line)
+	if node.paired {
+		return node, parser.HasChildren
+	}
+	return node, parser.NoChildren
+}
+
+func (p *shortcodeBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	block := node.(*shortcodeBlock)
+	if !block.paired {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	if isClosingShortcodeLine(line, block.call.Name) {
+		advanceLine(reader, line)
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (p *shortcodeBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *shortcodeBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *shortcodeBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// advanceLine moves past a tag line but not its newline; the last line of
+// the document may have none.
+func advanceLine(reader text.Reader, line []byte) {
+	n := len(line)
+	if n > 0 && line[n-1] == '\n' {
+		n--
+	}
+	reader.Advance(n)
+}
+
+func hasClosingShortcode(rest []byte, name string) bool {
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i+1], rest[i+1:]
+		} else {
+			rest = nil
+		}
+		if isClosingShortcodeLine(line, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isClosingShortcodeLine(line []byte, name string) bool {
+	tag := util.TrimRightSpace(util.TrimLeftSpace(line))
+	call, closing, n, ok := parseShortcodeTag(tag)
+	return ok && closing && n == len(tag) && call.Name == name
+}
+
+type shortcodeInlineParser struct{}
+
+func (p *shortcodeInlineParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+// Parse reads an inline tag. An opening tag whose closing tag follows on the
+// same line is paired: the text between is rendered as inline Markdown and
+// passed as .Inner, as in "text {{< note >}}**inner**{{< /note >}} text".
+func (p *shortcodeInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	call, closing, n, ok := parseShortcodeTag(line)
+	if !ok {
+		return nil
+	}
+	block.Advance(n)
+	if closing {
+		shortcodeStateOf(pc).warnf("closing shortcode %q has no opening tag", call.Name)
+		return &shortcodeInline{call: call, broken: true}
+	}
+	call.resolve(pc)
+
+	node := &shortcodeInline{call: call}
+	start, end, found := findInlineClosing(line[n:], call.Name)
+	if !found {
+		node.broken = checkUnclosed(&call, pc)
+		return node
+	}
+	node.inner = line[n : n+start]
+	node.innerDoc = markdown.Parser().Parse(text.NewReader(node.inner), newParseContext(shortcodeStateOf(pc)))
+	block.Advance(end)
+	return node
+}
+
+// findInlineClosing returns where the closing tag of name starts and ends in
+// rest. Tags of the same name in between nest.
+func findInlineClosing(rest []byte, name string) (start, end int, ok bool) {
+	depth := 0
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != '{' {
+			continue
+		}
+		call, closing, n, ok := parseShortcodeTag(rest[i:])
+		if !ok || call.Name != name {
+			continue
+		}
+		if !closing {
+			depth++
+		} else if depth > 0 {
+			depth--
+		} else {
+			return i, i + n, true
+		}
+		i += n - 1
+	}
+	return 0, 0, false
+}
+
+// checkUnclosed warns when a shortcode that renders .Inner has no closing
+// tag, and reports whether it should be shown as written.
+func checkUnclosed(call *shortcodeCall, pc parser.Context) bool {
+	if call.tmpl == nil || call.tmpl.Tree == nil || !strings.Contains(call.tmpl.Tree.Root.String(), ".Inner") {
+		return false
+	}
+	shortcodeStateOf(pc).warnf("shortcode %q has no closing {{< /%s >}}", call.Name, call.Name)
+	return true
+}
+
+func shortcodeStateOf(pc parser.Context) *shortcodeState {
+	if state, ok := pc.Get(shortcodeStateKey).(*shortcodeState); ok {
+		return state
+	}
+	return &shortcodeState{}
+}
+
+type shortcodeRenderer struct{}
+
+func (r *shortcodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindShortcodeBlock, r.renderBlock)
+	reg.Register(kindShortcodeInline, r.renderInline)
+}
+
+func (r *shortcodeRenderer) renderBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*shortcodeBlock)
+	if !entering {
+		_, _ = w.WriteString(n.suffix)
+		return ast.WalkContinue, nil
+	}
+
+	if n.unclosed {
+		_, _ = w.WriteString("<p>" + shortcodeError(n.call.Source) + "</p>\n")
+		return ast.WalkSkipChildren, nil
+	}
+	out, ok := executeShortcode(n.call, true, n.paired)
+	if !ok {
+		_, _ = w.WriteString(out + "\n")
+		return ast.WalkSkipChildren, nil
+	}
+	prefix, suffix, found := strings.Cut(out, shortcodeInnerMarker)
+	_, _ = w.WriteString(strings.TrimSpace(prefix) + "\n")
+	if !found {
+		return ast.WalkSkipChildren, nil
+	}
+	n.suffix = strings.TrimSpace(suffix) + "\n"
+	return ast.WalkContinue, nil
+}
+
+func (r *shortcodeRenderer) renderInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*shortcodeInline)
+	if n.broken {
+		_, _ = w.WriteString(shortcodeError(n.call.Source))
+		return ast.WalkSkipChildren, nil
+	}
+	out, ok := executeShortcode(n.call, false, n.innerDoc != nil)
+	if ok && n.innerDoc != nil {
+		var inner bytes.Buffer
+		if err := markdown.Renderer().Render(&inner, n.inner, n.innerDoc); err != nil {
+			return ast.WalkStop, err
+		}
+		// 行内内容只有一个段落，去掉外层的 <p>
+		html := strings.TrimSpace(inner.String())
+		if p, ok := strings.CutPrefix(html, "<p>"); ok && strings.Count(html, "<p>") == 1 {
+			html = strings.TrimSuffix(p, "</p>")
+		}
+		out = strings.Replace(out, shortcodeInnerMarker, html, 1)
+	}
+	_, _ = w.WriteString(strings.TrimSpace(out))
+	return ast.WalkSkipChildren, nil
+}
+
+// executeShortcode runs the template; when it is missing or fails, the tag is
+// shown as written so the problem is visible in the preview.
+func executeShortcode(call shortcodeCall, block, paired bool) (string, bool) {
+	t := call.tmpl
+	if t == nil {
+		return shortcodeError(call.Source), false
+	}
+	data := shortcodeData{
+		Name:  call.Name,
+		Args:  call.Args,
+		Named: call.Named,
+		Post:  call.Post,
+		Block: block,
+	}
+	if paired {
+		data.Inner = template.HTML(shortcodeInnerMarker)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Printf("shortcode %s: %v", call.Name, err)
+		return shortcodeError(call.Source), false
+	}
+	return buf.String(), true
+}
+
+func shortcodeError(source string) string {
+	return `<code class="shortcode-error">` + template.HTMLEscapeString(source) + `</code>`
+}
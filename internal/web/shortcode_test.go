@@ -0,0 +1,180 @@
+package web
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"myblog/internal/blog"
+
+	"github.com/yuin/goldmark/text"
+)
+
+func TestParseShortcodeTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		ok      bool
+		closing bool
+		n       int
+		call    string
+		args    []string
+		named   map[string]string
+	}{
+		{name: "positional", in: "{{< youtube abc >}} rest", ok: true, n: 19, call: "youtube", args: []string{"abc"}, named: map[string]string{}},
+		{name: "quoted and named", in: `{{< figure "/a b.png" caption="图 1" >}}`, ok: true, n: 41, call: "figure", args: []string{"/a b.png"}, named: map[string]string{"caption": "图 1"}},
+		{name: "backquoted", in: "{{< note `a \"b\"` >}}", ok: true, n: 20, call: "note", args: []string{`a "b"`}, named: map[string]string{}},
+		{name: "closing", in: "{{< /note >}}", ok: true, closing: true, n: 13, call: "note", named: map[string]string{}},
+		{name: "unterminated", in: "{{< note "},
+		{name: "invalid name", in: "{{< 1note >}}"},
+		{name: "empty", in: "{{< >}}"},
+		{name: "not a tag", in: "{{ note }}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call, closing, n, ok := parseShortcodeTag([]byte(tt.in))
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if closing != tt.closing || n != tt.n || call.Name != tt.call {
+				t.Errorf("got name %q closing %v n %d, want %q %v %d", call.Name, closing, n, tt.call, tt.closing, tt.n)
+			}
+			if !reflect.DeepEqual(call.Args, tt.args) || !reflect.DeepEqual(call.Named, tt.named) {
+				t.Errorf("got args %q named %q, want %q %q", call.Args, call.Named, tt.args, tt.named)
+			}
+			if call.Source != tt.in[:n] {
+				t.Errorf("source = %q, want %q", call.Source, tt.in[:n])
+			}
+		})
+	}
+}
+
+func TestShortcodeRendering(t *testing.T) {
+	lookup := func(slug string) (blog.Post, bool) {
+		switch slug {
+		case "hello":
+			return blog.Post{Slug: "hello", Title: "Hello"}, true
+		case "draft":
+			return blog.Post{Slug: "draft", Title: "Draft", IsDraft: true}, true
+		}
+		return blog.Post{}, false
+	}
+	tests := []struct {
+		name     string
+		in       string
+		html     string
+		warnings []string
+	}{
+		{
+			name: "paired block",
+			in:   "{{< note warning title=\"注意\" >}}\n**粗体**\n{{< /note >}}",
+			html: "<aside class=\"callout callout-warning\">\n  <p class=\"callout-title\">注意</p>\n<p><strong>粗体</strong></p>\n</aside>\n",
+		},
+		{
+			name: "paired inline",
+			in:   "文字 {{< note tip >}}**提示**{{< /note >}} 文字",
+			html: "<p>文字 <span class=\"callout callout-inline callout-tip\"><strong>提示</strong></span> 文字</p>\n",
+		},
+		{
+			name: "nested inline",
+			in:   "{{< note >}}外{{< note >}}内{{< /note >}}{{< /note >}}",
+			html: "<p><span class=\"callout callout-inline callout-note\">外<span class=\"callout callout-inline callout-note\">内</span></span></p>\n",
+		},
+		{
+			name: "single post card",
+			in:   "{{< post hello >}}",
+			html: "<a class=\"post-ref-card\" href=\"/posts/hello\">\n  <span class=\"post-ref-label\"></span>\n  <strong>Hello</strong>\n</a>\n",
+		},
+		{
+			name: "inline post link",
+			in:   "见 {{< post hello \"这篇\" >}}。",
+			html: "<p>见 <a class=\"post-ref\" href=\"/posts/hello\">这篇</a>。</p>\n",
+		},
+		{
+			name:     "unpublished post",
+			in:       "{{< post draft >}}",
+			html:     "<span class=\"post-ref is-broken\">draft</span>\n",
+			warnings: []string{`post shortcode references unpublished post "draft"`},
+		},
+		{
+			name:     "missing post",
+			in:       "{{< post missing >}}",
+			html:     "<span class=\"post-ref is-broken\">missing</span>\n",
+			warnings: []string{`post shortcode references missing post "missing"`},
+		},
+		{
+			name:     "unknown name",
+			in:       "{{< nope >}}",
+			html:     "<code class=\"shortcode-error\">{{&lt; nope &gt;}}</code>\n",
+			warnings: []string{`unknown shortcode "nope"`},
+		},
+		{
+			name:     "unmatched closing tag",
+			in:       "文字 {{< /note >}} 文字",
+			html:     "<p>文字 <code class=\"shortcode-error\">{{&lt; /note &gt;}}</code> 文字</p>\n",
+			warnings: []string{`closing shortcode "note" has no opening tag`},
+		},
+		{
+			name:     "unclosed block",
+			in:       "{{< note >}}\n内容",
+			html:     "<p><code class=\"shortcode-error\">{{&lt; note &gt;}}</code></p>\n<p>内容</p>\n",
+			warnings: []string{`shortcode "note" has no closing {{< /note >}}`},
+		},
+		{
+			name:     "unclosed inline",
+			in:       "文字 {{< note >}} 文字",
+			html:     "<p>文字 <code class=\"shortcode-error\">{{&lt; note &gt;}}</code> 文字</p>\n",
+			warnings: []string{`shortcode "note" has no closing {{< /note >}}`},
+		},
+		{
+			name: "inside code span",
+			in:   "`{{< youtube abc >}}`",
+			html: "<p><code>{{&lt; youtube abc &gt;}}</code></p>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &shortcodeState{lookup: lookup, templates: loadDefaultShortcodeTemplates()}
+			src := []byte(tt.in)
+			doc := markdown.Parser().Parse(text.NewReader(src), newParseContext(state))
+			var out bytes.Buffer
+			if err := markdown.Renderer().Render(&out, src, doc); err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if out.String() != tt.html {
+				t.Errorf("html\n got %q\nwant %q", out.String(), tt.html)
+			}
+			if !reflect.DeepEqual(state.warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", state.warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestReferencesPost(t *testing.T) {
+	slugs := map[string]bool{"hello": true}
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"positional", "见 {{< post hello >}}。", true},
+		{"named", `{{< post slug="hello" >}}`, true},
+		{"with link text", `{{< post hello "这篇" >}}`, true},
+		{"other slug", "{{< post world >}}", false},
+		{"other shortcode", "{{< youtube hello >}}", false},
+		{"closing tag", "{{< /post hello >}}", false},
+		{"after a broken tag", "{{< 1x {{< post hello >}}", true},
+		{"plain text", "hello", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := referencesPost(tt.content, slugs); got != tt.want {
+				t.Errorf("referencesPost(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,52 +3,229 @@ package web
 import (
 	"encoding/xml"
 	"net/http"
+	"strings"
+	"time"
+
+	"myblog/internal/blog"
 )
 
 type URL struct {
-	Loc        string `xml:"loc"`
-	LastMod    string `xml:"lastmod,omitempty"`
-	ChangeFreq string `xml:"changefreq,omitempty"`
-	Priority   string `xml:"priority,omitempty"`
+	Loc     string         `xml:"loc"`
+	LastMod string         `xml:"lastmod,omitempty"`
+	Images  []SitemapImage `xml:"image:image,omitempty"`
+}
+
+// SitemapImage is an image:image entry from the Google image sitemap
+// extension.
+type SitemapImage struct {
+	Loc string `xml:"image:loc"`
 }
 
 type URLSet struct {
 	XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	ImageNS string   `xml:"xmlns:image,attr,omitempty"`
 	URLs    []URL    `xml:"url"`
 }
 
-func (s *Server) Sitemap(w http.ResponseWriter, r *http.Request) {
-	baseURL := s.Config.SiteBaseURL
-	posts := s.Store.ListPublished()
+// SitemapIndex lists the sub-sitemaps served under /sitemaps/.
+type SitemapIndex struct {
+	XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []SitemapPointer `xml:"sitemap"`
+}
+
+type SitemapPointer struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
 
-	var urls []URL
+const sitemapImageNS = "http://www.google.com/schemas/sitemap-image/1.1"
 
-	// Homepage
-	urls = append(urls, URL{
-		Loc:        baseURL + "/",
-		ChangeFreq: "daily",
-		Priority:   "1.0",
-	})
+// sitemapSections are the sub-sitemaps, in the order the index lists them.
+var sitemapSections = []string{"posts", "pages", "tags"}
 
-	// Static pages
-	urls = append(urls, URL{Loc: baseURL + "/archive", Priority: "0.5"})
-	urls = append(urls, URL{Loc: baseURL + "/posts", Priority: "0.6"})
+// SitemapPaths lists the sitemap and robots.txt paths, for the static build.
+func SitemapPaths() []string {
+	paths := []string{"/sitemap.xml", "/robots.txt"}
+	for _, name := range sitemapSections {
+		paths = append(paths, "/sitemaps/"+name+".xml")
+	}
+	return paths
+}
 
-	// Posts
+// lastMod formats a sitemap date; the zero time is left out.
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// newest returns the latest UpdatedAt among posts.
+func newest(posts []blog.Post) time.Time {
+	var latest time.Time
 	for _, post := range posts {
-		urls = append(urls, URL{
-			Loc:        baseURL + "/posts/" + post.Slug,
-			LastMod:    post.UpdatedAt.Format("2006-01-02"),
-			ChangeFreq: "weekly",
-			Priority:   "0.8",
+		if post.UpdatedAt.After(latest) {
+			latest = post.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// Sitemap serves the sitemap index at /sitemap.xml.
+func (s *Server) Sitemap(w http.ResponseWriter, r *http.Request) {
+	var index SitemapIndex
+	for _, name := range sitemapSections {
+		urls := s.sitemapURLs(name)
+		var latest string
+		for _, u := range urls {
+			if u.LastMod > latest {
+				latest = u.LastMod
+			}
+		}
+		index.Sitemaps = append(index.Sitemaps, SitemapPointer{
+			Loc:     s.siteURL("/sitemaps/" + name + ".xml"),
+			LastMod: latest,
 		})
 	}
+	writeXML(w, index)
+}
+
+// SitemapSection serves /sitemaps/{posts,pages,tags}.xml.
+func (s *Server) SitemapSection(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/sitemaps/"), ".xml")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	urls := s.sitemapURLs(name)
+	if urls == nil {
+		http.NotFound(w, r)
+		return
+	}
+	set := URLSet{URLs: urls}
+	if name == "posts" {
+		set.ImageNS = sitemapImageNS
+	}
+	writeXML(w, set)
+}
+
+// sitemapURLs returns the entries of one sub-sitemap, or nil for an unknown
+// name.
+func (s *Server) sitemapURLs(name string) []URL {
+	posts := s.Store.ListPublished()
+	switch name {
+	case "posts":
+		urls := []URL{}
+		for _, post := range posts {
+			urls = append(urls, URL{
+				Loc:     s.siteURL(escapedPath("posts", post.Slug)),
+				LastMod: lastMod(post.UpdatedAt),
+				Images:  s.sitemapImages(post),
+			})
+		}
+		return urls
+
+	case "pages":
+		// 首页、列表与归档随最新文章更新
+		latest := lastMod(newest(posts))
+		urls := []URL{
+			{Loc: s.siteURL("/"), LastMod: latest},
+			{Loc: s.siteURL("/posts"), LastMod: latest},
+			{Loc: s.siteURL("/archive"), LastMod: latest},
+		}
+		for _, page := range s.publishedPages() {
+			urls = append(urls, URL{
+				Loc:     s.siteURL("/" + page.Path),
+				LastMod: lastMod(page.UpdatedAt),
+			})
+		}
+		for _, series := range s.seriesWithPosts() {
+			updated := newest(s.Store.ListBySeries(series.Slug))
+			if series.UpdatedAt.After(updated) {
+				updated = series.UpdatedAt
+			}
+			urls = append(urls, URL{
+				Loc:     s.siteURL(escapedPath("series", series.Slug)),
+				LastMod: lastMod(updated),
+			})
+		}
+		return urls
+
+	case "tags":
+		urls := []URL{}
+		for _, tag := range s.Store.TagCounts() {
+			urls = append(urls, URL{
+				Loc:     s.siteURL(escapedPath("tags", tag.Name)),
+				LastMod: lastMod(newest(s.Store.ListByTag(tag.Name))),
+			})
+		}
+		for _, category := range s.Store.CategoryCounts() {
+			urls = append(urls, URL{
+				Loc:     s.siteURL(escapedPath("categories", category.Name)),
+				LastMod: lastMod(newest(s.Store.ListByCategory(category.Name))),
+			})
+		}
+		return urls
+	}
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/xml")
+// sitemapImages lists the cover image and the images in the post body, each
+// once, as absolute URLs.
+func (s *Server) sitemapImages(post blog.Post) []SitemapImage {
+	sources := []string{post.CoverImage}
+	for _, m := range imgSrcPattern.FindAllStringSubmatch(post.ContentHTML, -1) {
+		sources = append(sources, m[1])
+	}
+	seen := map[string]bool{}
+	var images []SitemapImage
+	for _, src := range sources {
+		src = strings.TrimSpace(src)
+		if src == "" || strings.HasPrefix(src, "data:") {
+			continue
+		}
+		loc := s.assetURL(src)
+		if !strings.HasPrefix(loc, "http://") && !strings.HasPrefix(loc, "https://") {
+			continue
+		}
+		if !seen[loc] {
+			seen[loc] = true
+			images = append(images, SitemapImage{Loc: loc})
+		}
+	}
+	return images
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
 	w.Write([]byte(xml.Header))
 	enc := xml.NewEncoder(w)
 	enc.Indent("", "  ")
-	if err := enc.Encode(URLSet{URLs: urls}); err != nil {
+	if err := enc.Encode(v); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// defaultRobots is served when the site settings leave robots.txt empty.
+const defaultRobots = `User-agent: *
+Disallow: /admin/
+Disallow: /search
+`
+
+// Robots serves /robots.txt from the site settings and points crawlers at
+// the sitemap index.
+func (s *Server) Robots(w http.ResponseWriter, r *http.Request) {
+	rules := strings.TrimSpace(s.SiteStore.Get().Robots)
+	if rules == "" {
+		rules = strings.TrimSpace(defaultRobots)
+	}
+	var b strings.Builder
+	b.WriteString(rules)
+	b.WriteString("\n")
+	// 规则里没有写 Sitemap 时自动补上
+	if !strings.Contains(strings.ToLower(rules), "sitemap:") {
+		b.WriteString("\nSitemap: " + s.siteURL("/sitemap.xml") + "\n")
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
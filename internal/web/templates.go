@@ -0,0 +1,295 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"hash/fnv"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"myblog/internal/i18n"
+)
+
+// TemplateCache holds parsed templates keyed by theme and page. It is safe for
+// concurrent use by handlers.
+type TemplateCache struct {
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+}
+
+func (c *TemplateCache) get(key string) (*template.Template, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t, ok := c.templates[key]
+	return t, ok
+}
+
+func (c *TemplateCache) put(key string, t *template.Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.templates == nil {
+		c.templates = map[string]*template.Template{}
+	}
+	c.templates[key] = t
+}
+
+func (c *TemplateCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.templates = nil
+}
+
+// funcMap is the single set of functions available to every page and partial.
+// t translates UI messages into locale.
+func (s *Server) funcMap(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(key string, args ...any) string {
+			return i18n.T(locale, key, args...)
+		},
+		"assetURL": func(input string) string {
+			return s.assetURL(input)
+		},
+		"formatDate": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format("2006-01-02")
+		},
+		"isoTime": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(time.RFC3339)
+		},
+		"lower": func(input string) string {
+			return strings.ToLower(input)
+		},
+		"joinTags": func(tags []string) string {
+			return strings.Join(tags, ",")
+		},
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"escapedPath": escapedPath,
+	}
+}
+
+// templateLocale is the UI locale that baseData stored for the request.
+func templateLocale(data map[string]any) string {
+	if locale, ok := data["Locale"].(string); ok {
+		return locale
+	}
+	return i18n.DefaultLocale
+}
+
+func (s *Server) render(w http.ResponseWriter, page string, data map[string]any) {
+	t, err := s.templateFor(page, templateLocale(data))
+	if err != nil {
+		s.renderTemplateError(w, page, err)
+		return
+	}
+	// 先渲染到缓冲区，执行出错时还能换成错误页
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "base", data); err != nil {
+		s.renderTemplateError(w, page, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// renderPartial renders a template without the base layout, e.g. the search
+// results returned to HTMX requests.
+func (s *Server) renderPartial(w http.ResponseWriter, page string, data map[string]any) {
+	locale := templateLocale(data)
+	key := s.ActiveTheme().Manifest.Name + "/" + locale + "/partial/" + page
+	t, ok := s.TemplateCache.get(key)
+	if !ok {
+		var err error
+		t, err = template.New(path.Base(page)).Funcs(s.funcMap(locale)).ParseFS(s.templateFS(), page)
+		if err != nil {
+			s.renderTemplateError(w, page, err)
+			return
+		}
+		s.TemplateCache.put(key, t)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		s.renderTemplateError(w, page, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+func (s *Server) templateFor(page, locale string) (*template.Template, error) {
+	// 不同主题、不同语言的同名页面各自缓存，t 函数绑定在解析出的模板上
+	key := s.ActiveTheme().Manifest.Name + "/" + locale + "/" + page
+	if t, ok := s.TemplateCache.get(key); ok {
+		return t, nil
+	}
+
+	files := []string{"base.html", page}
+	if page == "search.html" {
+		files = append(files, "search_results.html")
+	}
+	t, err := template.New("").Funcs(s.funcMap(locale)).ParseFS(s.templateFS(), files...)
+	if err != nil {
+		return nil, err
+	}
+	s.TemplateCache.put(key, t)
+	return t, nil
+}
+
+// 开发模式下轮询模板目录的间隔
+const templateWatchInterval = 500 * time.Millisecond
+
+// watchTemplates polls the template directories on disk, i.e. the default
+// templates and every theme, and drops the parsed templates when any file is
+// added, removed or modified. Stored HTML is only re-rendered when a
+// shortcode template changed.
+func (s *Server) watchTemplates(interval time.Duration) {
+	last, lastShortcodes := templateDirsSignature()
+	for range time.Tick(interval) {
+		sig, shortcodes := templateDirsSignature()
+		if sig == last {
+			continue
+		}
+		last = sig
+		s.TemplateCache.reset()
+		s.themes.reset()
+		log.Println("Templates changed, reloading")
+		// 短代码模板的输出存在文章 HTML 里，需要重新渲染；其它模板在请求时才执行
+		if shortcodes != lastShortcodes {
+			lastShortcodes = shortcodes
+			s.rerenderForTemplates()
+		}
+	}
+}
+
+// templateDirsSignature hashes the name, size and modification time of every
+// file under the template directories, and separately of the shortcode
+// templates among them.
+func templateDirsSignature() (all, shortcodes uint64) {
+	h := fnv.New64a()
+	hs := fnv.New64a()
+	for _, dir := range []string{defaultTemplateDir, themesDir} {
+		_ = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			stamp := name + "\x00" + strconv.FormatInt(info.Size(), 10) + "\x00" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+			h.Write([]byte(stamp))
+			if strings.Contains(filepath.ToSlash(name), "/"+shortcodeDir+"/") {
+				hs.Write([]byte(stamp))
+			}
+			return nil
+		})
+	}
+	return h.Sum64(), hs.Sum64()
+}
+
+// templateErrorLocation matches "template: post.html:12:" at the start of
+// parse and execution errors.
+var templateErrorLocation = regexp.MustCompile(`template: ([^:\s]+):(\d+):`)
+
+// sourceLine is one line of the excerpt shown on the template error page.
+type sourceLine struct {
+	Num     int
+	Text    string
+	Current bool
+}
+
+// renderTemplateError replaces a page whose template failed to parse or
+// execute. In dev mode the page shows the error and the offending lines;
+// otherwise the details only go to the log.
+func (s *Server) renderTemplateError(w http.ResponseWriter, page string, err error) {
+	log.Printf("Template error in %s: %v", page, err)
+	data := map[string]any{"Page": page, "Dev": s.Config.Dev}
+	if s.Config.Dev {
+		data["Error"] = err.Error()
+		if m := templateErrorLocation.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[2])
+			data["File"] = m[1]
+			data["Line"] = line
+			data["Source"] = s.templateSource(m[1], line, 4)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := templateErrorPage.Execute(w, data); err != nil {
+		log.Printf("Template error page: %v", err)
+	}
+}
+
+// templateSource returns the lines around line in a template file of the
+// active theme.
+func (s *Server) templateSource(file string, line, context int) []sourceLine {
+	f, err := s.templateFS().Open(file)
+	if err != nil {
+		// 解析错误里可能是子目录中的模板，只报了文件名
+		return nil
+	}
+	defer f.Close()
+	var lines []sourceLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if n < line-context {
+			continue
+		}
+		if n > line+context {
+			break
+		}
+		lines = append(lines, sourceLine{Num: n, Text: scanner.Text(), Current: n == line})
+	}
+	return lines
+}
+
+// templateErrorPage does not use base.html, which may be the broken template.
+var templateErrorPage = template.Must(template.New("template_error").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>模板错误 - {{.Page}}</title>
+<style>
+body { font: 15px/1.6 -apple-system, "Segoe UI", "PingFang SC", sans-serif; margin: 40px auto; max-width: 960px; padding: 0 20px; color: #1f2937; }
+h1 { font-size: 22px; }
+.error { background: #fef2f2; border: 1px solid #fecaca; border-radius: 8px; padding: 12px 16px; white-space: pre-wrap; word-break: break-word; font-family: ui-monospace, Menlo, monospace; font-size: 13px; }
+.source { margin-top: 16px; border: 1px solid #e5e7eb; border-radius: 8px; overflow-x: auto; font-family: ui-monospace, Menlo, monospace; font-size: 13px; }
+.source div { white-space: pre; padding: 0 12px; }
+.source .current { background: #fee2e2; }
+.source span { display: inline-block; width: 40px; color: #9ca3af; user-select: none; }
+.muted { color: #6b7280; }
+</style>
+</head>
+<body>
+{{if .Dev}}
+<h1>模板 {{.Page}} 渲染失败</h1>
+<div class="error">{{.Error}}</div>
+{{if .File}}
+<p class="muted">{{.File}} 第 {{.Line}} 行</p>
+{{if .Source}}<div class="source">{{range .Source}}<div{{if .Current}} class="current"{{end}}><span>{{.Num}}</span>{{.Text}}</div>{{end}}</div>{{end}}
+{{end}}
+<p class="muted">保存模板后刷新页面即可重新加载。</p>
+{{else}}
+<h1>页面暂时无法显示</h1>
+<p class="muted">渲染页面时出错，请稍后再试。</p>
+{{end}}
+</body>
+</html>
+`))
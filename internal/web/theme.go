@@ -0,0 +1,153 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// 内置的默认主题就是仓库里的模板与静态资源目录，默认嵌入在二进制中。
+// 这些路径都相对于工作目录：themes 与开发模式下读取的模板、静态资源目录
+// 和 data、uploads 一样，要求从仓库根目录（或部署目录）启动。
+const (
+	DefaultThemeName   = "default"
+	themesDir          = "themes"
+	themeManifestFile  = "theme.json"
+	defaultTemplateDir = "internal/web/templates"
+	defaultStaticDir   = "static"
+)
+
+// ThemeManifest describes a theme in themes/{name}/theme.json.
+type ThemeManifest struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Author      string `json:"author"`
+}
+
+// Theme is a set of templates and static assets. A theme only needs to
+// contain the files it changes: every template and asset it lacks is taken
+// from the default theme.
+type Theme struct {
+	Manifest ThemeManifest
+	// Dir is themes/{name}, or empty for the built-in default theme.
+	Dir string
+}
+
+func defaultTheme() Theme {
+	return Theme{Manifest: ThemeManifest{Name: DefaultThemeName, Title: "默认主题"}}
+}
+
+// layer puts a directory of the theme (templates or static) over the same
+// directory of the default theme.
+func (t Theme) layer(dir string, base fs.FS) fs.FS {
+	if t.Dir == "" {
+		return base
+	}
+	return layeredFS{os.DirFS(filepath.Join(t.Dir, dir)), base}
+}
+
+// loadTheme reads themes/{name}/theme.json.
+func loadTheme(name string) (Theme, error) {
+	if name == "" || name == DefaultThemeName {
+		return defaultTheme(), nil
+	}
+	if filepath.Base(name) != name {
+		return Theme{}, errors.New("invalid theme name: " + name)
+	}
+	dir := filepath.Join(themesDir, name)
+	data, err := os.ReadFile(filepath.Join(dir, themeManifestFile))
+	if err != nil {
+		return Theme{}, err
+	}
+	var manifest ThemeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Theme{}, err
+	}
+	// 目录名是主题的唯一标识，清单中的 name 只用于展示
+	manifest.Name = name
+	if manifest.Title == "" {
+		manifest.Title = name
+	}
+	return Theme{Manifest: manifest, Dir: dir}, nil
+}
+
+// AvailableThemes returns the default theme followed by every directory under
+// themes/ that has a valid manifest.
+func AvailableThemes() []ThemeManifest {
+	themes := []ThemeManifest{defaultTheme().Manifest}
+	entries, err := os.ReadDir(themesDir)
+	if err != nil {
+		return themes
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultThemeName {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if theme, err := loadTheme(name); err == nil {
+			themes = append(themes, theme.Manifest)
+		}
+	}
+	return themes
+}
+
+// themeCache keeps loaded manifests so a request does not re-read theme.json.
+type themeCache struct {
+	mu     sync.Mutex
+	themes map[string]Theme
+}
+
+func (c *themeCache) get(name string) Theme {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if theme, ok := c.themes[name]; ok {
+		return theme
+	}
+	theme, err := loadTheme(name)
+	if err != nil {
+		log.Printf("Failed to load theme %q, using default: %v", name, err)
+		theme = defaultTheme()
+	}
+	if c.themes == nil {
+		c.themes = map[string]Theme{}
+	}
+	c.themes[name] = theme
+	return theme
+}
+
+// reset forgets loaded manifests, so edited theme.json files are re-read.
+func (c *themeCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.themes = nil
+}
+
+// ActiveTheme is the theme chosen in the site settings, else the THEME
+// config value, else the default theme.
+func (s *Server) ActiveTheme() Theme {
+	name := s.SiteStore.Get().Theme
+	if name == "" {
+		name = s.Config.Theme
+	}
+	return s.themes.get(name)
+}
+
+// templateFS holds the active theme's templates over the default ones.
+func (s *Server) templateFS() fs.FS {
+	return s.ActiveTheme().layer("templates", defaultAssets(defaultTemplateDir))
+}
+
+// staticFS holds the active theme's static files over the default ones.
+func (s *Server) staticFS() fs.FS {
+	return s.ActiveTheme().layer("static", defaultAssets(defaultStaticDir))
+}